@@ -36,4 +36,7 @@ var (
 
 	// NetworkPolicyTemplateKind is the singular kind name for NetworkPolicyTemplates.
 	NetworkPolicyTemplateKind = "NetworkPolicyTemplate"
+
+	// NetworkPolicyAdmissionRuleKind is the singular kind name for NetworkPolicyAdmissionRules.
+	NetworkPolicyAdmissionRuleKind = "NetworkPolicyAdmissionRule"
 )
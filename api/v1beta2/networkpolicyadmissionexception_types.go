@@ -0,0 +1,94 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkPolicyAdmissionExceptionSpec defines the desired state of NetworkPolicyAdmissionException.
+// +kubebuilder:validation:XValidation:rule="has(self.cidr) != has(self.entity)",message="exactly one of cidr or entity must be set"
+type NetworkPolicyAdmissionExceptionSpec struct {
+	// Namespace is the namespace of the network policies the exception applies to.
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Rule is the name of the NetworkPolicyAdmissionRule the exception applies to.
+	// +kubebuilder:validation:MinLength=1
+	Rule string `json:"rule"`
+
+	// CIDR is an IP range the rule allows within the namespace until the exception expires.
+	// Peers contained in the range are allowed.
+	// +kubebuilder:validation:XValidation:rule="isCIDR(self)",message="cidr must be a valid CIDR"
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+
+	// Entity is an entity the rule allows within the namespace until the exception expires.
+	// +kubebuilder:validation:Enum=all;world;world-ipv4;world-ipv6;cluster;host;remote-node;kube-apiserver;ingress;health;init;unmanaged;none
+	// +optional
+	Entity string `json:"entity,omitempty"`
+
+	// ExpiresAt is the time the exception expires.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+
+	// Reason explains why the exception is needed, e.g. an incident ticket.
+	// +kubebuilder:validation:MinLength=1
+	Reason string `json:"reason"`
+}
+
+// NetworkPolicyAdmissionExceptionStatus defines the observed state of NetworkPolicyAdmissionException.
+type NetworkPolicyAdmissionExceptionStatus struct {
+	// Expired is true once the exception has expired and affected network policies have been audited again.
+	// +optional
+	Expired bool `json:"expired,omitempty"`
+}
+
+// IsActive reports whether the exception is in effect at the given time.
+func (e *NetworkPolicyAdmissionException) IsActive(now metav1.Time) bool {
+	return now.Before(&e.Spec.ExpiresAt)
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`
+//+kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.spec.rule`
+//+kubebuilder:printcolumn:name="Expires At",type=date,JSONPath=`.spec.expiresAt`
+//+kubebuilder:printcolumn:name="Expired",type=boolean,JSONPath=`.status.expired`
+
+// NetworkPolicyAdmissionException is the Schema for the networkpolicyadmissionexceptions API.
+// It temporarily allows an IP range or entity forbidden by a NetworkPolicyAdmissionRule in one namespace.
+type NetworkPolicyAdmissionException struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkPolicyAdmissionExceptionSpec   `json:"spec"`
+	Status NetworkPolicyAdmissionExceptionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NetworkPolicyAdmissionExceptionList contains a list of NetworkPolicyAdmissionException.
+type NetworkPolicyAdmissionExceptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkPolicyAdmissionException `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkPolicyAdmissionException{}, &NetworkPolicyAdmissionExceptionList{})
+}
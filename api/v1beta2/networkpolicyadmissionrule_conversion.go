@@ -0,0 +1,142 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+// ConvertTo converts the rule to the v1beta3 hub version.
+// The v1beta3 status is left empty until the controller audits existing network policies against the rule.
+func (src *NetworkPolicyAdmissionRule) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta3.NetworkPolicyAdmissionRule)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1beta3.NetworkPolicyAdmissionRuleSpec{
+		NamespaceSelector: v1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+			MatchLabels:             src.Spec.NamespaceSelector.MatchLabels,
+			MatchExpressions:        src.Spec.NamespaceSelector.MatchExpressions,
+			Namespaces:              src.Spec.NamespaceSelector.Namespaces,
+			ExcludeNamespaces:       src.Spec.NamespaceSelector.ExcludeNamespaces,
+			ExcludeLabels:           src.Spec.NamespaceSelector.ExcludeLabels,
+			ExcludeLabelExpressions: src.Spec.NamespaceSelector.ExcludeLabelExpressions,
+		},
+		EnforcementAction: v1beta3.NetworkPolicyAdmissionRuleEnforcementAction(src.Spec.EnforcementAction),
+	}
+	for _, r := range src.Spec.ForbiddenIPRanges {
+		dst.Spec.ForbiddenIPRanges = append(dst.Spec.ForbiddenIPRanges, v1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			CIDR: r.CIDR,
+			Type: v1beta3.NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, r := range src.Spec.AllowedIPRanges {
+		dst.Spec.AllowedIPRanges = append(dst.Spec.AllowedIPRanges, v1beta3.NetworkPolicyAdmissionRuleAllowedIPRanges{
+			CIDR: r.CIDR,
+			Type: v1beta3.NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, e := range src.Spec.ForbiddenEntities {
+		dst.Spec.ForbiddenEntities = append(dst.Spec.ForbiddenEntities, v1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
+			Entity: e.Entity,
+			Type:   v1beta3.NetworkPolicyAdmissionRuleType(e.Type),
+			Match:  v1beta3.NetworkPolicyAdmissionRuleEntityMatch(e.Match),
+		})
+	}
+	for _, r := range src.Spec.ProtectedIPRanges {
+		dst.Spec.ProtectedIPRanges = append(dst.Spec.ProtectedIPRanges, v1beta3.NetworkPolicyAdmissionRuleProtectedIPRanges{
+			CIDR: r.CIDR,
+			Type: v1beta3.NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, e := range src.Spec.ProtectedEntities {
+		dst.Spec.ProtectedEntities = append(dst.Spec.ProtectedEntities, v1beta3.NetworkPolicyAdmissionRuleProtectedEntity{
+			Entity: e.Entity,
+			Type:   v1beta3.NetworkPolicyAdmissionRuleType(e.Type),
+			Match:  v1beta3.NetworkPolicyAdmissionRuleEntityMatch(e.Match),
+		})
+	}
+	for _, r := range src.Spec.RequiredDenyIPRanges {
+		dst.Spec.RequiredDenyIPRanges = append(dst.Spec.RequiredDenyIPRanges, v1beta3.NetworkPolicyAdmissionRuleRequiredDenyIPRanges{
+			CIDR: r.CIDR,
+			Type: v1beta3.NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	dst.Status = v1beta3.NetworkPolicyAdmissionRuleStatus{}
+	return nil
+}
+
+// ConvertFrom converts the rule from the v1beta3 hub version.
+// The status is ok once the rule has been audited; the results of the audit are not kept.
+func (dst *NetworkPolicyAdmissionRule) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta3.NetworkPolicyAdmissionRule)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = NetworkPolicyAdmissionRuleSpec{
+		NamespaceSelector: NetworkPolicyAdmissionRuleNamespaceSelector{
+			MatchLabels:             src.Spec.NamespaceSelector.MatchLabels,
+			MatchExpressions:        src.Spec.NamespaceSelector.MatchExpressions,
+			Namespaces:              src.Spec.NamespaceSelector.Namespaces,
+			ExcludeNamespaces:       src.Spec.NamespaceSelector.ExcludeNamespaces,
+			ExcludeLabels:           src.Spec.NamespaceSelector.ExcludeLabels,
+			ExcludeLabelExpressions: src.Spec.NamespaceSelector.ExcludeLabelExpressions,
+		},
+		EnforcementAction: NetworkPolicyAdmissionRuleEnforcementAction(src.Spec.EnforcementAction),
+	}
+	for _, r := range src.Spec.ForbiddenIPRanges {
+		dst.Spec.ForbiddenIPRanges = append(dst.Spec.ForbiddenIPRanges, NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			CIDR: r.CIDR,
+			Type: NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, r := range src.Spec.AllowedIPRanges {
+		dst.Spec.AllowedIPRanges = append(dst.Spec.AllowedIPRanges, NetworkPolicyAdmissionRuleAllowedIPRanges{
+			CIDR: r.CIDR,
+			Type: NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, e := range src.Spec.ForbiddenEntities {
+		dst.Spec.ForbiddenEntities = append(dst.Spec.ForbiddenEntities, NetworkPolicyAdmissionRuleForbiddenEntity{
+			Entity: e.Entity,
+			Type:   NetworkPolicyAdmissionRuleType(e.Type),
+			Match:  NetworkPolicyAdmissionRuleEntityMatch(e.Match),
+		})
+	}
+	for _, r := range src.Spec.ProtectedIPRanges {
+		dst.Spec.ProtectedIPRanges = append(dst.Spec.ProtectedIPRanges, NetworkPolicyAdmissionRuleProtectedIPRanges{
+			CIDR: r.CIDR,
+			Type: NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	for _, e := range src.Spec.ProtectedEntities {
+		dst.Spec.ProtectedEntities = append(dst.Spec.ProtectedEntities, NetworkPolicyAdmissionRuleProtectedEntity{
+			Entity: e.Entity,
+			Type:   NetworkPolicyAdmissionRuleType(e.Type),
+			Match:  NetworkPolicyAdmissionRuleEntityMatch(e.Match),
+		})
+	}
+	for _, r := range src.Spec.RequiredDenyIPRanges {
+		dst.Spec.RequiredDenyIPRanges = append(dst.Spec.RequiredDenyIPRanges, NetworkPolicyAdmissionRuleRequiredDenyIPRanges{
+			CIDR: r.CIDR,
+			Type: NetworkPolicyAdmissionRuleType(r.Type),
+		})
+	}
+	dst.Status = ""
+	if src.Status.LastAuditTime != nil {
+		dst.Status = NetworkPolicyAdmissionRuleOK
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package v1beta2
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+func TestNetworkPolicyAdmissionRuleConversion(t *testing.T) {
+	legacy := &NetworkPolicyAdmissionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "forbid-bmc", Generation: 2},
+		Spec: NetworkPolicyAdmissionRuleSpec{
+			NamespaceSelector: NetworkPolicyAdmissionRuleNamespaceSelector{
+				MatchLabels:       map[string]string{"tenant": "true"},
+				MatchExpressions:  []metav1.LabelSelectorRequirement{{Key: "team", Operator: metav1.LabelSelectorOpExists}},
+				Namespaces:        []string{"app"},
+				ExcludeNamespaces: []string{"kube-system"},
+				ExcludeLabels:     map[string]string{"team": "neco"},
+				ExcludeLabelExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "privileged", Operator: metav1.LabelSelectorOpIn, Values: []string{"true"}},
+				},
+			},
+			ForbiddenIPRanges:    []NetworkPolicyAdmissionRuleForbiddenIPRanges{{CIDR: "10.72.16.0/20", Type: NetworkPolicyAdmissionRuleTypeEgress}},
+			AllowedIPRanges:      []NetworkPolicyAdmissionRuleAllowedIPRanges{{CIDR: "10.0.0.0/8", Type: NetworkPolicyAdmissionRuleTypeAll}},
+			ForbiddenEntities:    []NetworkPolicyAdmissionRuleForbiddenEntity{{Entity: "world", Type: NetworkPolicyAdmissionRuleTypeEgress, Match: NetworkPolicyAdmissionRuleEntityMatchExact}},
+			ProtectedIPRanges:    []NetworkPolicyAdmissionRuleProtectedIPRanges{{CIDR: "10.64.0.0/24", Type: NetworkPolicyAdmissionRuleTypeEgress}},
+			ProtectedEntities:    []NetworkPolicyAdmissionRuleProtectedEntity{{Entity: "kube-apiserver", Type: NetworkPolicyAdmissionRuleTypeAll, Match: NetworkPolicyAdmissionRuleEntityMatchHierarchy}},
+			RequiredDenyIPRanges: []NetworkPolicyAdmissionRuleRequiredDenyIPRanges{{CIDR: "10.96.0.0/12", Type: NetworkPolicyAdmissionRuleTypeIngress}},
+			EnforcementAction:    NetworkPolicyAdmissionRuleEnforcementActionWarn,
+		},
+	}
+
+	hub := &v1beta3.NetworkPolicyAdmissionRule{}
+	if err := legacy.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.EnforcementAction != v1beta3.NetworkPolicyAdmissionRuleEnforcementActionWarn || len(hub.Spec.RequiredDenyIPRanges) != 1 {
+		t.Errorf("unexpected spec %+v", hub.Spec)
+	}
+
+	roundTrip := &NetworkPolicyAdmissionRule{}
+	if err := roundTrip.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(roundTrip, legacy) {
+		t.Errorf("v1beta2 round trip changed the rule:\n%+v\nwant:\n%+v", roundTrip, legacy)
+	}
+
+	// The results of the audit are lost on the way back, and only reported as ok.
+	now := metav1.Now()
+	hub.Status = v1beta3.NetworkPolicyAdmissionRuleStatus{
+		ObservedGeneration: 2,
+		LastAuditTime:      &now,
+		ViolationCount:     1,
+		Violations:         []v1beta3.NetworkPolicyAdmissionRuleViolation{{Kind: "CiliumNetworkPolicy", Namespace: "app", Name: "bmc", Message: "overlaps"}},
+	}
+	legacy = &NetworkPolicyAdmissionRule{}
+	if err := legacy.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Status != NetworkPolicyAdmissionRuleOK {
+		t.Errorf("unexpected status %q", legacy.Status)
+	}
+	roundTripHub := &v1beta3.NetworkPolicyAdmissionRule{}
+	if err := legacy.ConvertTo(roundTripHub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(roundTripHub.Spec, hub.Spec) {
+		t.Errorf("v1beta3 round trip changed the spec:\n%+v\nwant:\n%+v", roundTripHub.Spec, hub.Spec)
+	}
+	if !equality.Semantic.DeepEqual(roundTripHub.Status, v1beta3.NetworkPolicyAdmissionRuleStatus{}) {
+		t.Errorf("unexpected status %+v", roundTripHub.Status)
+	}
+}
@@ -21,17 +21,32 @@ import (
 )
 
 // NetworkPolicyAdmissionRuleStatus defines the observed state of NetworkPolicyAdmissionRule.
+// It is ok once existing network policies have been audited against the rule.
+// The results of the audit are only available in v1beta3.
 type NetworkPolicyAdmissionRuleStatus string
 
 // NetworkPolicyAdmissionRuleType defines the type of network connection the rules apply to.
 type NetworkPolicyAdmissionRuleType string
 
+// NetworkPolicyAdmissionRuleEnforcementAction defines what happens when a network policy violates a rule.
+type NetworkPolicyAdmissionRuleEnforcementAction string
+
+// NetworkPolicyAdmissionRuleEntityMatch defines how requested entities are compared with a forbidden entity.
+type NetworkPolicyAdmissionRuleEntityMatch string
+
 const (
 	NetworkPolicyAdmissionRuleOK NetworkPolicyAdmissionRuleStatus = "ok"
 
 	NetworkPolicyAdmissionRuleTypeAll     NetworkPolicyAdmissionRuleType = "all"
 	NetworkPolicyAdmissionRuleTypeEgress  NetworkPolicyAdmissionRuleType = "egress"
 	NetworkPolicyAdmissionRuleTypeIngress NetworkPolicyAdmissionRuleType = "ingress"
+
+	NetworkPolicyAdmissionRuleEntityMatchHierarchy NetworkPolicyAdmissionRuleEntityMatch = "hierarchy"
+	NetworkPolicyAdmissionRuleEntityMatchExact     NetworkPolicyAdmissionRuleEntityMatch = "exact"
+
+	NetworkPolicyAdmissionRuleEnforcementActionDeny   NetworkPolicyAdmissionRuleEnforcementAction = "deny"
+	NetworkPolicyAdmissionRuleEnforcementActionWarn   NetworkPolicyAdmissionRuleEnforcementAction = "warn"
+	NetworkPolicyAdmissionRuleEnforcementActionDryRun NetworkPolicyAdmissionRuleEnforcementAction = "dryrun"
 )
 
 // NetworkPolicyAdmissionRuleSpec defines the desired state of NetworkPolicyAdmissionRule.
@@ -40,12 +55,50 @@ type NetworkPolicyAdmissionRuleSpec struct {
 	NamespaceSelector NetworkPolicyAdmissionRuleNamespaceSelector `json:"namespaceSelector,omitempty"`
 	// ForbiddenIPRanges defines IP ranges whose usage must be forbidden in network policies.
 	ForbiddenIPRanges []NetworkPolicyAdmissionRuleForbiddenIPRanges `json:"forbiddenIPRanges,omitempty"`
+	// AllowedIPRanges defines IP ranges that network policies are restricted to.
+	// If set, CIDRs in network policies must be fully contained in the allowed IP ranges of the same connection type,
+	// and entities overlapping world and DNS names are rejected as their addresses are not known in advance.
+	AllowedIPRanges []NetworkPolicyAdmissionRuleAllowedIPRanges `json:"allowedIPRanges,omitempty"`
 	// ForbiddenEntities defines entities whose usage must be forbidden in network policies.
 	ForbiddenEntities []NetworkPolicyAdmissionRuleForbiddenEntity `json:"forbiddenEntities,omitempty"`
+	// ProtectedIPRanges defines IP ranges that egressDeny and ingressDeny sections of network policies must not deny,
+	// so that deny rules cannot shadow connections required by administrators.
+	// Forbidden and allowed IP ranges and forbidden entities only apply to egress and ingress sections.
+	// +optional
+	ProtectedIPRanges []NetworkPolicyAdmissionRuleProtectedIPRanges `json:"protectedIPRanges,omitempty"`
+	// ProtectedEntities defines entities that egressDeny and ingressDeny sections of network policies must not deny.
+	// +optional
+	ProtectedEntities []NetworkPolicyAdmissionRuleProtectedEntity `json:"protectedEntities,omitempty"`
+	// RequiredDenyIPRanges defines IP ranges that network policies must deny whenever they allow them.
+	// A CIDR in an egress or ingress section overlapping a required-deny range must come with
+	// an egressDeny or ingressDeny section in the same rule covering the range.
+	// +optional
+	RequiredDenyIPRanges []NetworkPolicyAdmissionRuleRequiredDenyIPRanges `json:"requiredDenyIPRanges,omitempty"`
+	// EnforcementAction defines what happens when a network policy violates the rule.
+	// "deny" rejects the network policy.
+	// "warn" accepts the network policy and returns an admission warning naming the rule and the offending peer.
+	// "dryrun" accepts the network policy and only records an event on the rule.
+	// +kubebuilder:validation:Enum=deny;warn;dryrun
+	// +kubebuilder:default=deny
+	// +optional
+	EnforcementAction NetworkPolicyAdmissionRuleEnforcementAction `json:"enforcementAction,omitempty"`
 }
 
 // NetworkPolicyAdmissionRuleNamespaceSelector defines how namespaces should be selected.
+// A namespace is selected if it matches all the inclusion criteria that are set and none of the exclusion criteria.
 type NetworkPolicyAdmissionRuleNamespaceSelector struct {
+	// MatchLabels defines labels that a namespace must have to be selected.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// MatchExpressions defines label expressions that a namespace must match to be selected.
+	MatchExpressions []metav1.LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// Namespaces defines the names of namespaces to be selected.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExcludeNamespaces defines the names of namespaces to be excluded.
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
 	// ExcludeLabels defines labels through which a namespace should be excluded.
 	ExcludeLabels map[string]string `json:"excludeLabels,omitempty"`
 
@@ -64,6 +117,17 @@ type NetworkPolicyAdmissionRuleForbiddenIPRanges struct {
 	Type NetworkPolicyAdmissionRuleType `json:"type"`
 }
 
+// NetworkPolicyAdmissionRuleAllowedIPRanges defines allowed IP ranges.
+type NetworkPolicyAdmissionRuleAllowedIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
 // NetworkPolicyAdmissionRuleForbiddenEntity defines forbidden entities.
 type NetworkPolicyAdmissionRuleForbiddenEntity struct {
 	// Entity name.
@@ -73,12 +137,66 @@ type NetworkPolicyAdmissionRuleForbiddenEntity struct {
 	// +kubebuilder:validation:Enum=egress;ingress;all
 	// +default:"all"
 	Type NetworkPolicyAdmissionRuleType `json:"type"`
+
+	// Match defines how requested entities are compared with the forbidden entity.
+	// "hierarchy" forbids entities that are, contain, or are contained in the forbidden entity,
+	// e.g. forbidding world also forbids world-ipv4 and all.
+	// "exact" forbids only the entity itself.
+	// +kubebuilder:validation:Enum=hierarchy;exact
+	// +kubebuilder:default=hierarchy
+	// +optional
+	Match NetworkPolicyAdmissionRuleEntityMatch `json:"match,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleProtectedIPRanges defines IP ranges that deny sections must not deny.
+type NetworkPolicyAdmissionRuleProtectedIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to. egress protects the range from egressDeny sections,
+	// and ingress from ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
+// NetworkPolicyAdmissionRuleProtectedEntity defines entities that deny sections must not deny.
+type NetworkPolicyAdmissionRuleProtectedEntity struct {
+	// Entity name.
+	Entity string `json:"entity"`
+
+	// Type of connection the rule applies to. egress protects the entity from egressDeny sections,
+	// and ingress from ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+
+	// Match defines how denied entities are compared with the protected entity.
+	// "hierarchy" protects the entity from denials of entities that are, contain, or are contained in it,
+	// e.g. protecting kube-apiserver also rejects denying cluster.
+	// "exact" only rejects denying the entity itself.
+	// +kubebuilder:validation:Enum=hierarchy;exact
+	// +kubebuilder:default=hierarchy
+	// +optional
+	Match NetworkPolicyAdmissionRuleEntityMatch `json:"match,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleRequiredDenyIPRanges defines IP ranges that network policies allowing them must deny.
+type NetworkPolicyAdmissionRuleRequiredDenyIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to. egress requires egress sections allowing the range
+	// to be paired with egressDeny sections, and ingress ingress sections with ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:resource:scope=Cluster
 //+kubebuilder:subresource:status
-//+kubebuilder:storageversion
+//+kubebuilder:deprecatedversion:warning="tenet.cybozu.io/v1beta2 NetworkPolicyAdmissionRule is deprecated; use tenet.cybozu.io/v1beta3 NetworkPolicyAdmissionRule"
 
 // NetworkPolicyAdmissionRule is the Schema for the networkpolicyadmissionrules API.
 type NetworkPolicyAdmissionRule struct {
@@ -0,0 +1,68 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+// ConvertTo converts the template to the v1beta3 hub version.
+// The status becomes the state of the v1beta3 status; the other status fields are
+// left empty until the controller reconciles the template.
+func (src *NetworkPolicyTemplate) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta3.NetworkPolicyTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = v1beta3.NetworkPolicyTemplateSpec{
+		ClusterWide:    src.Spec.ClusterWide,
+		PolicyTemplate: src.Spec.PolicyTemplate,
+		Paused:         src.Spec.Paused,
+	}
+	if src.Spec.Rollout != nil {
+		dst.Spec.Rollout = &v1beta3.NetworkPolicyTemplateRolloutStrategy{
+			CanarySelector: src.Spec.Rollout.CanarySelector,
+			BatchSize:      src.Spec.Rollout.BatchSize,
+			BatchInterval:  src.Spec.Rollout.BatchInterval,
+		}
+	}
+	dst.Status = v1beta3.NetworkPolicyTemplateStatus{
+		State: v1beta3.NetworkPolicyTemplateState(src.Status),
+	}
+	return nil
+}
+
+// ConvertFrom converts the template from the v1beta3 hub version.
+// Only the state of the v1beta3 status is kept.
+func (dst *NetworkPolicyTemplate) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta3.NetworkPolicyTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = NetworkPolicyTemplateSpec{
+		ClusterWide:    src.Spec.ClusterWide,
+		PolicyTemplate: src.Spec.PolicyTemplate,
+		Paused:         src.Spec.Paused,
+	}
+	if src.Spec.Rollout != nil {
+		dst.Spec.Rollout = &NetworkPolicyTemplateRolloutStrategy{
+			CanarySelector: src.Spec.Rollout.CanarySelector,
+			BatchSize:      src.Spec.Rollout.BatchSize,
+			BatchInterval:  src.Spec.Rollout.BatchInterval,
+		}
+	}
+	dst.Status = NetworkPolicyTemplateStatus(src.Status.State)
+	return nil
+}
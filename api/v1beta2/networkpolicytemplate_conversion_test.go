@@ -0,0 +1,65 @@
+package v1beta2
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+func TestNetworkPolicyTemplateConversion(t *testing.T) {
+	legacy := &NetworkPolicyTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-intra-namespace", Generation: 3},
+		Spec: NetworkPolicyTemplateSpec{
+			ClusterWide:    true,
+			PolicyTemplate: "apiVersion: cilium.io/v2\nkind: CiliumClusterwideNetworkPolicy\n",
+			Rollout: &NetworkPolicyTemplateRolloutStrategy{
+				CanarySelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "neco"}},
+				BatchSize:      10,
+				BatchInterval:  metav1.Duration{Duration: 10 * time.Minute},
+			},
+			Paused: true,
+		},
+		Status: NetworkPolicyTemplateOK,
+	}
+
+	hub := &v1beta3.NetworkPolicyTemplate{}
+	if err := legacy.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Status.State != v1beta3.NetworkPolicyTemplateOK {
+		t.Errorf("unexpected state %q", hub.Status.State)
+	}
+	if hub.Spec.Rollout == nil || hub.Spec.Rollout.BatchSize != 10 || !hub.Spec.Paused {
+		t.Errorf("unexpected spec %+v", hub.Spec)
+	}
+
+	roundTrip := &NetworkPolicyTemplate{}
+	if err := roundTrip.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(roundTrip, legacy) {
+		t.Errorf("v1beta2 round trip changed the template:\n%+v\nwant:\n%+v", roundTrip, legacy)
+	}
+
+	// The detailed status of v1beta3 is lost on the way back, apart from the state.
+	hub.Status.ObservedGeneration = 3
+	hub.Status.Revisions = []v1beta3.NetworkPolicyTemplateRevision{{Revision: "b7c6bbbb3e", Generation: 3}}
+	legacy = &NetworkPolicyTemplate{}
+	if err := legacy.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+	roundTripHub := &v1beta3.NetworkPolicyTemplate{}
+	if err := legacy.ConvertTo(roundTripHub); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(roundTripHub.Spec, hub.Spec) {
+		t.Errorf("v1beta3 round trip changed the spec:\n%+v\nwant:\n%+v", roundTripHub.Spec, hub.Spec)
+	}
+	if !equality.Semantic.DeepEqual(roundTripHub.Status, v1beta3.NetworkPolicyTemplateStatus{State: v1beta3.NetworkPolicyTemplateOK}) {
+		t.Errorf("unexpected status %+v", roundTripHub.Status)
+	}
+}
@@ -36,14 +36,42 @@ type NetworkPolicyTemplateSpec struct {
 	ClusterWide bool `json:"clusterwide,omitempty"`
 	// PolicyTemplate is a template for creating NetworkPolicies
 	PolicyTemplate string `json:"policyTemplate"`
+	// Rollout is the strategy to update existing policies when PolicyTemplate changes.
+	// Existing policies are all updated at once when it is not set.
+	// +optional
+	Rollout *NetworkPolicyTemplateRolloutStrategy `json:"rollout,omitempty"`
+	// Paused stops the controller from creating, updating and deleting the generated policies,
+	// which are left as they are. A template being deleted is kept until it is unpaused, so that its policies are not deleted.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// NetworkPolicyTemplateRolloutStrategy describes how a new revision of a template is rolled out to namespaces.
+// Canary namespaces are updated first; once they are all updated, the other namespaces are updated
+// by batches in the order of their names, waiting BatchInterval between batches.
+type NetworkPolicyTemplateRolloutStrategy struct {
+	// CanarySelector selects the namespaces updated first.
+	// +optional
+	CanarySelector *metav1.LabelSelector `json:"canarySelector,omitempty"`
+
+	// BatchSize is the maximum number of namespaces updated at a time after the canary namespaces.
+	// All remaining namespaces are updated at once when it is zero.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// BatchInterval is the time to wait after updating canary namespaces or a batch before updating the next batch.
+	// +optional
+	BatchInterval metav1.Duration `json:"batchInterval,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:resource:scope=Cluster
 //+kubebuilder:subresource:status
-//+kubebuilder:storageversion
+//+kubebuilder:deprecatedversion:warning="tenet.cybozu.io/v1beta2 NetworkPolicyTemplate is deprecated; use tenet.cybozu.io/v1beta3 NetworkPolicyTemplate"
 
 // NetworkPolicyTemplate is the Schema for the networkpolicytemplates API.
+// Its status only holds the overall state of the template; use v1beta3 for the detailed status.
 type NetworkPolicyTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -25,6 +25,96 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionException) DeepCopyInto(out *NetworkPolicyAdmissionException) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionException.
+func (in *NetworkPolicyAdmissionException) DeepCopy() *NetworkPolicyAdmissionException {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionException)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkPolicyAdmissionException) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionExceptionList) DeepCopyInto(out *NetworkPolicyAdmissionExceptionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NetworkPolicyAdmissionException, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionExceptionList.
+func (in *NetworkPolicyAdmissionExceptionList) DeepCopy() *NetworkPolicyAdmissionExceptionList {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionExceptionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkPolicyAdmissionExceptionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionExceptionSpec) DeepCopyInto(out *NetworkPolicyAdmissionExceptionSpec) {
+	*out = *in
+	in.ExpiresAt.DeepCopyInto(&out.ExpiresAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionExceptionSpec.
+func (in *NetworkPolicyAdmissionExceptionSpec) DeepCopy() *NetworkPolicyAdmissionExceptionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionExceptionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionExceptionStatus) DeepCopyInto(out *NetworkPolicyAdmissionExceptionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionExceptionStatus.
+func (in *NetworkPolicyAdmissionExceptionStatus) DeepCopy() *NetworkPolicyAdmissionExceptionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionExceptionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyAdmissionRule) DeepCopyInto(out *NetworkPolicyAdmissionRule) {
 	*out = *in
@@ -51,6 +141,21 @@ func (in *NetworkPolicyAdmissionRule) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionRuleAllowedIPRanges) DeepCopyInto(out *NetworkPolicyAdmissionRuleAllowedIPRanges) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionRuleAllowedIPRanges.
+func (in *NetworkPolicyAdmissionRuleAllowedIPRanges) DeepCopy() *NetworkPolicyAdmissionRuleAllowedIPRanges {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionRuleAllowedIPRanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyAdmissionRuleForbiddenEntity) DeepCopyInto(out *NetworkPolicyAdmissionRuleForbiddenEntity) {
 	*out = *in
@@ -116,6 +221,30 @@ func (in *NetworkPolicyAdmissionRuleList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyAdmissionRuleNamespaceSelector) DeepCopyInto(out *NetworkPolicyAdmissionRuleNamespaceSelector) {
 	*out = *in
+	if in.MatchLabels != nil {
+		in, out := &in.MatchLabels, &out.MatchLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.MatchExpressions != nil {
+		in, out := &in.MatchExpressions, &out.MatchExpressions
+		*out = make([]v1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeNamespaces != nil {
+		in, out := &in.ExcludeNamespaces, &out.ExcludeNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.ExcludeLabels != nil {
 		in, out := &in.ExcludeLabels, &out.ExcludeLabels
 		*out = make(map[string]string, len(*in))
@@ -142,6 +271,51 @@ func (in *NetworkPolicyAdmissionRuleNamespaceSelector) DeepCopy() *NetworkPolicy
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionRuleProtectedEntity) DeepCopyInto(out *NetworkPolicyAdmissionRuleProtectedEntity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionRuleProtectedEntity.
+func (in *NetworkPolicyAdmissionRuleProtectedEntity) DeepCopy() *NetworkPolicyAdmissionRuleProtectedEntity {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionRuleProtectedEntity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionRuleProtectedIPRanges) DeepCopyInto(out *NetworkPolicyAdmissionRuleProtectedIPRanges) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionRuleProtectedIPRanges.
+func (in *NetworkPolicyAdmissionRuleProtectedIPRanges) DeepCopy() *NetworkPolicyAdmissionRuleProtectedIPRanges {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionRuleProtectedIPRanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyAdmissionRuleRequiredDenyIPRanges) DeepCopyInto(out *NetworkPolicyAdmissionRuleRequiredDenyIPRanges) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionRuleRequiredDenyIPRanges.
+func (in *NetworkPolicyAdmissionRuleRequiredDenyIPRanges) DeepCopy() *NetworkPolicyAdmissionRuleRequiredDenyIPRanges {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyAdmissionRuleRequiredDenyIPRanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyAdmissionRuleSpec) DeepCopyInto(out *NetworkPolicyAdmissionRuleSpec) {
 	*out = *in
@@ -151,11 +325,31 @@ func (in *NetworkPolicyAdmissionRuleSpec) DeepCopyInto(out *NetworkPolicyAdmissi
 		*out = make([]NetworkPolicyAdmissionRuleForbiddenIPRanges, len(*in))
 		copy(*out, *in)
 	}
+	if in.AllowedIPRanges != nil {
+		in, out := &in.AllowedIPRanges, &out.AllowedIPRanges
+		*out = make([]NetworkPolicyAdmissionRuleAllowedIPRanges, len(*in))
+		copy(*out, *in)
+	}
 	if in.ForbiddenEntities != nil {
 		in, out := &in.ForbiddenEntities, &out.ForbiddenEntities
 		*out = make([]NetworkPolicyAdmissionRuleForbiddenEntity, len(*in))
 		copy(*out, *in)
 	}
+	if in.ProtectedIPRanges != nil {
+		in, out := &in.ProtectedIPRanges, &out.ProtectedIPRanges
+		*out = make([]NetworkPolicyAdmissionRuleProtectedIPRanges, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProtectedEntities != nil {
+		in, out := &in.ProtectedEntities, &out.ProtectedEntities
+		*out = make([]NetworkPolicyAdmissionRuleProtectedEntity, len(*in))
+		copy(*out, *in)
+	}
+	if in.RequiredDenyIPRanges != nil {
+		in, out := &in.RequiredDenyIPRanges, &out.RequiredDenyIPRanges
+		*out = make([]NetworkPolicyAdmissionRuleRequiredDenyIPRanges, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyAdmissionRuleSpec.
@@ -173,7 +367,7 @@ func (in *NetworkPolicyTemplate) DeepCopyInto(out *NetworkPolicyTemplate) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyTemplate.
@@ -226,9 +420,35 @@ func (in *NetworkPolicyTemplateList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkPolicyTemplateRolloutStrategy) DeepCopyInto(out *NetworkPolicyTemplateRolloutStrategy) {
+	*out = *in
+	if in.CanarySelector != nil {
+		in, out := &in.CanarySelector, &out.CanarySelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	out.BatchInterval = in.BatchInterval
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyTemplateRolloutStrategy.
+func (in *NetworkPolicyTemplateRolloutStrategy) DeepCopy() *NetworkPolicyTemplateRolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkPolicyTemplateRolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkPolicyTemplateSpec) DeepCopyInto(out *NetworkPolicyTemplateSpec) {
 	*out = *in
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(NetworkPolicyTemplateRolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkPolicyTemplateSpec.
@@ -0,0 +1,42 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1beta3 contains API Schema definitions for the tenet v1beta3 API group
+// +kubebuilder:object:generate=true
+// +groupName=tenet.cybozu.io
+package v1beta3
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is group version used to register these objects.
+	GroupVersion = schema.GroupVersion{Group: "tenet.cybozu.io", Version: "v1beta3"}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types in this group-version to the given scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+
+	// NetworkPolicyTemplateKind is the singular kind name for NetworkPolicyTemplates.
+	NetworkPolicyTemplateKind = "NetworkPolicyTemplate"
+
+	// NetworkPolicyAdmissionRuleKind is the singular kind name for NetworkPolicyAdmissionRules.
+	NetworkPolicyAdmissionRuleKind = "NetworkPolicyAdmissionRule"
+)
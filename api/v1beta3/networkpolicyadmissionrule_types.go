@@ -0,0 +1,250 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkPolicyAdmissionRuleType defines the type of network connection the rules apply to.
+type NetworkPolicyAdmissionRuleType string
+
+// NetworkPolicyAdmissionRuleEnforcementAction defines what happens when a network policy violates a rule.
+type NetworkPolicyAdmissionRuleEnforcementAction string
+
+// NetworkPolicyAdmissionRuleEntityMatch defines how requested entities are compared with a forbidden entity.
+type NetworkPolicyAdmissionRuleEntityMatch string
+
+const (
+	NetworkPolicyAdmissionRuleTypeAll     NetworkPolicyAdmissionRuleType = "all"
+	NetworkPolicyAdmissionRuleTypeEgress  NetworkPolicyAdmissionRuleType = "egress"
+	NetworkPolicyAdmissionRuleTypeIngress NetworkPolicyAdmissionRuleType = "ingress"
+
+	NetworkPolicyAdmissionRuleEntityMatchHierarchy NetworkPolicyAdmissionRuleEntityMatch = "hierarchy"
+	NetworkPolicyAdmissionRuleEntityMatchExact     NetworkPolicyAdmissionRuleEntityMatch = "exact"
+
+	NetworkPolicyAdmissionRuleEnforcementActionDeny   NetworkPolicyAdmissionRuleEnforcementAction = "deny"
+	NetworkPolicyAdmissionRuleEnforcementActionWarn   NetworkPolicyAdmissionRuleEnforcementAction = "warn"
+	NetworkPolicyAdmissionRuleEnforcementActionDryRun NetworkPolicyAdmissionRuleEnforcementAction = "dryrun"
+)
+
+// NetworkPolicyAdmissionRuleSpec defines the desired state of NetworkPolicyAdmissionRule.
+type NetworkPolicyAdmissionRuleSpec struct {
+	// NamespaceSelector qualifies which namespaces the rules should apply to.
+	NamespaceSelector NetworkPolicyAdmissionRuleNamespaceSelector `json:"namespaceSelector,omitempty"`
+	// ForbiddenIPRanges defines IP ranges whose usage must be forbidden in network policies.
+	ForbiddenIPRanges []NetworkPolicyAdmissionRuleForbiddenIPRanges `json:"forbiddenIPRanges,omitempty"`
+	// AllowedIPRanges defines IP ranges that network policies are restricted to.
+	// If set, CIDRs in network policies must be fully contained in the allowed IP ranges of the same connection type,
+	// and entities overlapping world and DNS names are rejected as their addresses are not known in advance.
+	AllowedIPRanges []NetworkPolicyAdmissionRuleAllowedIPRanges `json:"allowedIPRanges,omitempty"`
+	// ForbiddenEntities defines entities whose usage must be forbidden in network policies.
+	ForbiddenEntities []NetworkPolicyAdmissionRuleForbiddenEntity `json:"forbiddenEntities,omitempty"`
+	// ProtectedIPRanges defines IP ranges that egressDeny and ingressDeny sections of network policies must not deny,
+	// so that deny rules cannot shadow connections required by administrators.
+	// Forbidden and allowed IP ranges and forbidden entities only apply to egress and ingress sections.
+	// +optional
+	ProtectedIPRanges []NetworkPolicyAdmissionRuleProtectedIPRanges `json:"protectedIPRanges,omitempty"`
+	// ProtectedEntities defines entities that egressDeny and ingressDeny sections of network policies must not deny.
+	// +optional
+	ProtectedEntities []NetworkPolicyAdmissionRuleProtectedEntity `json:"protectedEntities,omitempty"`
+	// RequiredDenyIPRanges defines IP ranges that network policies must deny whenever they allow them.
+	// A CIDR in an egress or ingress section overlapping a required-deny range must come with
+	// an egressDeny or ingressDeny section in the same rule covering the range.
+	// +optional
+	RequiredDenyIPRanges []NetworkPolicyAdmissionRuleRequiredDenyIPRanges `json:"requiredDenyIPRanges,omitempty"`
+	// EnforcementAction defines what happens when a network policy violates the rule.
+	// "deny" rejects the network policy.
+	// "warn" accepts the network policy and returns an admission warning naming the rule and the offending peer.
+	// "dryrun" accepts the network policy and only records an event on the rule.
+	// +kubebuilder:validation:Enum=deny;warn;dryrun
+	// +kubebuilder:default=deny
+	// +optional
+	EnforcementAction NetworkPolicyAdmissionRuleEnforcementAction `json:"enforcementAction,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleNamespaceSelector defines how namespaces should be selected.
+// A namespace is selected if it matches all the inclusion criteria that are set and none of the exclusion criteria.
+type NetworkPolicyAdmissionRuleNamespaceSelector struct {
+	// MatchLabels defines labels that a namespace must have to be selected.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// MatchExpressions defines label expressions that a namespace must match to be selected.
+	MatchExpressions []metav1.LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// Namespaces defines the names of namespaces to be selected.
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// ExcludeNamespaces defines the names of namespaces to be excluded.
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	// ExcludeLabels defines labels through which a namespace should be excluded.
+	ExcludeLabels map[string]string `json:"excludeLabels,omitempty"`
+
+	// ExcludeLabelExpressions defines labels through which a namespace should be excluded by some expressions.
+	ExcludeLabelExpressions []metav1.LabelSelectorRequirement `json:"excludeLabelExpressions,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleForbiddenIPRanges defines forbidden IP ranges.
+type NetworkPolicyAdmissionRuleForbiddenIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
+// NetworkPolicyAdmissionRuleAllowedIPRanges defines allowed IP ranges.
+type NetworkPolicyAdmissionRuleAllowedIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
+// NetworkPolicyAdmissionRuleForbiddenEntity defines forbidden entities.
+type NetworkPolicyAdmissionRuleForbiddenEntity struct {
+	// Entity name.
+	Entity string `json:"entity"`
+
+	// Type of connection the rule applies to.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+
+	// Match defines how requested entities are compared with the forbidden entity.
+	// "hierarchy" forbids entities that are, contain, or are contained in the forbidden entity,
+	// e.g. forbidding world also forbids world-ipv4 and all.
+	// "exact" forbids only the entity itself.
+	// +kubebuilder:validation:Enum=hierarchy;exact
+	// +kubebuilder:default=hierarchy
+	// +optional
+	Match NetworkPolicyAdmissionRuleEntityMatch `json:"match,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleProtectedIPRanges defines IP ranges that deny sections must not deny.
+type NetworkPolicyAdmissionRuleProtectedIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to. egress protects the range from egressDeny sections,
+	// and ingress from ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
+// NetworkPolicyAdmissionRuleProtectedEntity defines entities that deny sections must not deny.
+type NetworkPolicyAdmissionRuleProtectedEntity struct {
+	// Entity name.
+	Entity string `json:"entity"`
+
+	// Type of connection the rule applies to. egress protects the entity from egressDeny sections,
+	// and ingress from ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+
+	// Match defines how denied entities are compared with the protected entity.
+	// "hierarchy" protects the entity from denials of entities that are, contain, or are contained in it,
+	// e.g. protecting kube-apiserver also rejects denying cluster.
+	// "exact" only rejects denying the entity itself.
+	// +kubebuilder:validation:Enum=hierarchy;exact
+	// +kubebuilder:default=hierarchy
+	// +optional
+	Match NetworkPolicyAdmissionRuleEntityMatch `json:"match,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleRequiredDenyIPRanges defines IP ranges that network policies allowing them must deny.
+type NetworkPolicyAdmissionRuleRequiredDenyIPRanges struct {
+	// CIDR range.
+	CIDR string `json:"cidr"`
+
+	// Type of connection the rule applies to. egress requires egress sections allowing the range
+	// to be paired with egressDeny sections, and ingress ingress sections with ingressDeny sections.
+	// +kubebuilder:validation:Enum=egress;ingress;all
+	// +default:"all"
+	Type NetworkPolicyAdmissionRuleType `json:"type"`
+}
+
+// NetworkPolicyAdmissionRuleStatus defines the observed state of NetworkPolicyAdmissionRule.
+type NetworkPolicyAdmissionRuleStatus struct {
+	// ObservedGeneration is the generation of the rule that existing network policies were last audited against.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastAuditTime is the time existing network policies were last audited against the rule.
+	// +optional
+	LastAuditTime *metav1.Time `json:"lastAuditTime,omitempty"`
+
+	// ViolationCount is the number of existing network policies violating the rule.
+	// +optional
+	ViolationCount int `json:"violationCount,omitempty"`
+
+	// Violations lists existing network policies violating the rule.
+	// The list is truncated when there are too many violations.
+	// +optional
+	Violations []NetworkPolicyAdmissionRuleViolation `json:"violations,omitempty"`
+}
+
+// NetworkPolicyAdmissionRuleViolation describes an existing network policy violating a rule.
+type NetworkPolicyAdmissionRuleViolation struct {
+	// Kind of the network policy.
+	Kind string `json:"kind"`
+
+	// Namespace of the network policy. Empty for clusterwide network policies.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the network policy.
+	Name string `json:"name"`
+
+	// Message describes how the network policy violates the rule.
+	Message string `json:"message"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// NetworkPolicyAdmissionRule is the Schema for the networkpolicyadmissionrules API.
+type NetworkPolicyAdmissionRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkPolicyAdmissionRuleSpec   `json:"spec"`
+	Status NetworkPolicyAdmissionRuleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NetworkPolicyAdmissionRuleList contains a list of NetworkPolicyAdmissionRule.
+type NetworkPolicyAdmissionRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkPolicyAdmissionRule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkPolicyAdmissionRule{}, &NetworkPolicyAdmissionRuleList{})
+}
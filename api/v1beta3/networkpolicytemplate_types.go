@@ -0,0 +1,217 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkPolicyTemplateState is the overall state of a NetworkPolicyTemplate.
+// +kubebuilder:validation:Enum=ok;invalid
+type NetworkPolicyTemplateState string
+
+const (
+	NetworkPolicyTemplateOK      NetworkPolicyTemplateState = "ok"
+	NetworkPolicyTemplateInvalid NetworkPolicyTemplateState = "invalid"
+)
+
+// Condition types of NetworkPolicyTemplate.
+const (
+	// NetworkPolicyTemplateAdmissionDenied is true when the policies rendered for some namespaces
+	// violate NetworkPolicyAdmissionRules, and are therefore not applied.
+	NetworkPolicyTemplateAdmissionDenied = "AdmissionDenied"
+
+	// NetworkPolicyTemplatePaused is true when the reconciliation of the template, or of some namespaces, is paused.
+	NetworkPolicyTemplatePaused = "Paused"
+)
+
+// Reasons for pausing the reconciliation of a namespace.
+const (
+	// NetworkPolicyTemplateSpecPaused means the template is paused by its spec.
+	NetworkPolicyTemplateSpecPaused = "TemplatePaused"
+
+	// NetworkPolicyTemplateNamespacePaused means the namespace is paused by the tenet.cybozu.io/paused annotation.
+	NetworkPolicyTemplateNamespacePaused = "NamespacePaused"
+)
+
+// NetworkPolicyTemplateStatus defines the observed state of NetworkPolicyTemplate
+type NetworkPolicyTemplateStatus struct {
+	// State is invalid when the template cannot be rendered for some opted-in namespaces, and ok otherwise.
+	// +optional
+	State NetworkPolicyTemplateState `json:"state,omitempty"`
+
+	// ObservedGeneration is the generation of the template that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the template.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// AdmissionDenials lists the namespaces whose rendered policy violates NetworkPolicyAdmissionRules.
+	// +optional
+	AdmissionDenials []NetworkPolicyTemplateAdmissionDenial `json:"admissionDenials,omitempty"`
+
+	// PausedNamespaces lists the namespaces whose policy is left as it is because the reconciliation is paused.
+	// +optional
+	PausedNamespaces []NetworkPolicyTemplatePausedNamespace `json:"pausedNamespaces,omitempty"`
+
+	// Rollout is the progress of the rollout of the current revision of the template.
+	// +optional
+	Rollout NetworkPolicyTemplateRolloutStatus `json:"rollout,omitempty"`
+
+	// Revisions is the history of the policy template, most recent first.
+	// The controller keeps a bounded number of revisions.
+	// +optional
+	Revisions []NetworkPolicyTemplateRevision `json:"revisions,omitempty"`
+}
+
+// NetworkPolicyTemplateRevision is a version of the policy template that the controller reconciled.
+type NetworkPolicyTemplateRevision struct {
+	// Revision is a hash of PolicyTemplate, recorded in the annotations of the policies rendered from it.
+	Revision string `json:"revision"`
+
+	// Generation is the generation of the template that last introduced the revision.
+	Generation int64 `json:"generation"`
+
+	// PolicyTemplate is the source of the revision.
+	PolicyTemplate string `json:"policyTemplate"`
+
+	// CreationTimestamp is the time the controller last introduced the revision.
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+}
+
+// NetworkPolicyTemplateRolloutStatus describes the progress of a rollout.
+type NetworkPolicyTemplateRolloutStatus struct {
+	// Revision is the revision being rolled out, computed from the policy template.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// PreviousRevision is the revision rolled out before Revision.
+	// +optional
+	PreviousRevision string `json:"previousRevision,omitempty"`
+
+	// RollingBack is true when Revision is a rollback to the previous revision, which is applied to all namespaces at once.
+	// +optional
+	RollingBack bool `json:"rollingBack,omitempty"`
+
+	// UpdatedNamespaces is the number of opted-in namespaces whose policy is rendered from Revision.
+	// +optional
+	UpdatedNamespaces int `json:"updatedNamespaces,omitempty"`
+
+	// PendingNamespaces is the number of opted-in namespaces whose policy is still rendered from an older revision.
+	// +optional
+	PendingNamespaces int `json:"pendingNamespaces,omitempty"`
+
+	// LastBatchTime is the time namespaces were last updated by the rollout.
+	// +optional
+	LastBatchTime *metav1.Time `json:"lastBatchTime,omitempty"`
+}
+
+// NetworkPolicyTemplateAdmissionDenial describes a namespace whose rendered policy is denied by NetworkPolicyAdmissionRules.
+type NetworkPolicyTemplateAdmissionDenial struct {
+	// Namespace is the opted-in namespace.
+	Namespace string `json:"namespace"`
+
+	// Rules are the names of the NetworkPolicyAdmissionRules denying the policy.
+	Rules []string `json:"rules"`
+
+	// Message describes the violations.
+	Message string `json:"message"`
+}
+
+// NetworkPolicyTemplatePausedNamespace describes a namespace whose reconciliation is paused.
+type NetworkPolicyTemplatePausedNamespace struct {
+	// Namespace is the paused namespace.
+	Namespace string `json:"namespace"`
+
+	// Reason is TemplatePaused or NamespacePaused.
+	Reason string `json:"reason"`
+
+	// Message is the reason of the pause given in the annotation of the namespace, if any.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// NetworkPolicyTemplateSpec defines the desired state of NetworkPolicyTemplate.
+type NetworkPolicyTemplateSpec struct {
+	// ClusterWide indicates whether the generated templates are clusterwide templates
+	//+kubebuilder:default=false
+	ClusterWide bool `json:"clusterwide,omitempty"`
+	// PolicyTemplate is a template for creating NetworkPolicies
+	PolicyTemplate string `json:"policyTemplate"`
+	// Rollout is the strategy to update existing policies when PolicyTemplate changes.
+	// Existing policies are all updated at once when it is not set.
+	// +optional
+	Rollout *NetworkPolicyTemplateRolloutStrategy `json:"rollout,omitempty"`
+	// Paused stops the controller from creating, updating and deleting the generated policies,
+	// which are left as they are. A template being deleted is kept until it is unpaused, so that its policies are not deleted.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// NetworkPolicyTemplateRolloutStrategy describes how a new revision of a template is rolled out to namespaces.
+// Canary namespaces are updated first; once they are all updated, the other namespaces are updated
+// by batches in the order of their names, waiting BatchInterval between batches.
+type NetworkPolicyTemplateRolloutStrategy struct {
+	// CanarySelector selects the namespaces updated first.
+	// +optional
+	CanarySelector *metav1.LabelSelector `json:"canarySelector,omitempty"`
+
+	// BatchSize is the maximum number of namespaces updated at a time after the canary namespaces.
+	// All remaining namespaces are updated at once when it is zero.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// BatchInterval is the time to wait after updating canary namespaces or a batch before updating the next batch.
+	// +optional
+	BatchInterval metav1.Duration `json:"batchInterval,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+
+// NetworkPolicyTemplate is the Schema for the networkpolicytemplates API.
+type NetworkPolicyTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the spec for the NetworkPolicyTemplate
+	Spec NetworkPolicyTemplateSpec `json:"spec"`
+
+	// Status represents the status of the NetworkPolicyTemplate
+	// +optional
+	Status NetworkPolicyTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NetworkPolicyTemplateList contains a list of NetworkPolicyTemplate.
+type NetworkPolicyTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkPolicyTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkPolicyTemplate{}, &NetworkPolicyTemplateList{})
+}
@@ -19,12 +19,15 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -36,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	"github.com/cybozu-go/tenet/controllers"
 	"github.com/cybozu-go/tenet/hooks"
 	//+kubebuilder:scaffold:imports
@@ -49,6 +53,7 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(tenetv1beta2.AddToScheme(scheme))
+	utilruntime.Must(tenetv1beta3.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -57,12 +62,20 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var serviceAccountName string
+	var auditInterval time.Duration
+	var privileged hooks.PrivilegedIdentities
+	var privilegedConfigMap string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&serviceAccountName, "service-account-name", "system:serviceaccount:tenet-system:tenet-controller-manager", "The name of the service account associated attached to the controller.")
+	flag.DurationVar(&auditInterval, "audit-interval", 10*time.Minute, "The interval between audits of existing network policies against NetworkPolicyAdmissionRules.")
+	flag.Func("privileged-users", "Comma-separated list of users allowed to change generated network policies.", listFlag(&privileged.Users))
+	flag.Func("privileged-groups", "Comma-separated list of groups allowed to change generated network policies.", listFlag(&privileged.Groups))
+	flag.Func("privileged-service-accounts", "Comma-separated list of service accounts, as <namespace>:<name>, allowed to change generated network policies.", listFlag(&privileged.ServiceAccounts))
+	flag.StringVar(&privilegedConfigMap, "privileged-identities-configmap", "", "The name of a ConfigMap in the namespace of the controller listing identities allowed to change generated network policies.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -71,6 +84,15 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if privilegedConfigMap != "" {
+		ns := os.Getenv("POD_NAMESPACE")
+		if ns == "" {
+			setupLog.Error(nil, "POD_NAMESPACE must be set to use --privileged-identities-configmap")
+			os.Exit(1)
+		}
+		privileged.ConfigMap = types.NamespacedName{Namespace: ns, Name: privilegedConfigMap}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Client: client.Options{
@@ -97,17 +119,39 @@ func main() {
 
 	ctx := ctrl.SetupSignalHandler()
 	if err = (&controllers.NetworkPolicyTemplateReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("NetworkPolicyTemplate"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("NetworkPolicyTemplate"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorder("tenet-controller"),
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicyTemplate")
 		os.Exit(1)
 	}
+	if err = (&controllers.NetworkPolicyAdmissionRuleReconciler{
+		Client:        mgr.GetClient(),
+		Log:           ctrl.Log.WithName("controllers").WithName("NetworkPolicyAdmissionRule"),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorder("tenet-controller"),
+		AuditInterval: auditInterval,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicyAdmissionRule")
+		os.Exit(1)
+	}
+	if err = (&controllers.NetworkPolicyAdmissionExceptionReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("NetworkPolicyAdmissionException"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorder("tenet-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NetworkPolicyAdmissionException")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	hooks.SetupNetworkPolicyAdmissionRuleWebhook(mgr, dec)
-	hooks.SetupCiliumNetworkPolicyWebhook(mgr, dec, serviceAccountName)
+	hooks.SetupNetworkPolicyTemplateWebhook(mgr, dec)
+	hooks.SetupCiliumNetworkPolicyWebhook(mgr, dec, serviceAccountName, privileged)
+	hooks.SetupCiliumClusterwideNetworkPolicyWebhook(mgr, dec, serviceAccountName, privileged)
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -124,3 +168,11 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// listFlag returns a flag.Func callback appending comma-separated values to list.
+func listFlag(list *[]string) func(string) error {
+	return func(s string) error {
+		*list = append(*list, strings.Split(s, ",")...)
+		return nil
+	}
+}
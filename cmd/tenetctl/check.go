@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/policy"
+)
+
+// Output formats of tenetctl check.
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJUnit = "junit"
+)
+
+// checkedPolicy is the result of the evaluation of a network policy.
+type checkedPolicy struct {
+	File       string             `json:"file"`
+	Kind       string             `json:"kind"`
+	Namespace  string             `json:"namespace,omitempty"`
+	Name       string             `json:"name"`
+	Violations []checkedViolation `json:"violations"`
+}
+
+// checkedViolation is a violation found in a network policy.
+type checkedViolation struct {
+	Rule              string   `json:"rule"`
+	EnforcementAction string   `json:"enforcementAction"`
+	Section           string   `json:"section"`
+	Kind              string   `json:"kind"`
+	Value             string   `json:"value"`
+	Forbidden         string   `json:"forbidden,omitempty"`
+	Allowed           []string `json:"allowed,omitempty"`
+	Protected         string   `json:"protected,omitempty"`
+	RequiredDeny      string   `json:"requiredDeny,omitempty"`
+	Path              string   `json:"path"`
+	Message           string   `json:"message"`
+}
+
+// denied reports whether the violation would make the webhook reject the policy.
+func (v checkedViolation) denied() bool {
+	return v.EnforcementAction == string(tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionDeny)
+}
+
+func (p checkedPolicy) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s", p.Kind, p.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", p.Kind, p.Namespace, p.Name)
+}
+
+// denials returns the violations of the policy that would make the webhook reject it.
+func (p checkedPolicy) denials() []checkedViolation {
+	var vs []checkedViolation
+	for _, v := range p.Violations {
+		if v.denied() {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// runCheck evaluates network policies against NetworkPolicyAdmissionRules like the admission webhook does.
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var ruleFiles, namespaceFiles []string
+	fs.Func("rules", "A file containing NetworkPolicyAdmissionRules. Can be repeated.", func(s string) error {
+		ruleFiles = append(ruleFiles, s)
+		return nil
+	})
+	fs.Func("namespaces", "A file containing Namespaces the policies belong to. Can be repeated.", func(s string) error {
+		namespaceFiles = append(namespaceFiles, s)
+		return nil
+	})
+	defaultNamespace := fs.String("namespace", "default", "The namespace of CiliumNetworkPolicies without one.")
+	format := fs.String("output", formatText, "The output format, one of text, json or junit.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tenetctl check --rules FILE [--namespaces FILE] [--output FORMAT] POLICY_FILE...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if len(ruleFiles) == 0 || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *format != formatText && *format != formatJSON && *format != formatJUnit {
+		fmt.Fprintf(stderr, "unknown output format %q\n", *format)
+		return 2
+	}
+
+	results, err := check(ruleFiles, namespaceFiles, fs.Args(), *defaultNamespace, &stdinReader{r: stdin}, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	switch *format {
+	case formatJSON:
+		err = writeCheckJSON(stdout, results)
+	case formatJUnit:
+		err = writeCheckJUnit(stdout, results)
+	default:
+		err = writeCheckText(stdout, results)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, r := range results {
+		if len(r.denials()) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// convertRule converts the manifest of a NetworkPolicyAdmissionRule, converting v1beta2 rules to v1beta3.
+func convertRule(m manifest, npar *tenetv1beta3.NetworkPolicyAdmissionRule) error {
+	if m.GroupVersionKind() != tenetv1beta2.GroupVersion.WithKind(tenetv1beta2.NetworkPolicyAdmissionRuleKind) {
+		return convert(m, npar)
+	}
+	legacy := &tenetv1beta2.NetworkPolicyAdmissionRule{}
+	if err := convert(m, legacy); err != nil {
+		return err
+	}
+	if err := legacy.ConvertTo(npar); err != nil {
+		return fmt.Errorf("%s: %w", m.source, err)
+	}
+	return nil
+}
+
+// check evaluates the policies of policyFiles against the rules of ruleFiles.
+// CiliumClusterwideNetworkPolicies are skipped with a message written to stderr, as the admission webhook does not evaluate them.
+func check(ruleFiles, namespaceFiles, policyFiles []string, defaultNamespace string, stdin *stdinReader, stderr io.Writer) ([]checkedPolicy, error) {
+	manifests, err := readManifests(ruleFiles, stdin)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]tenetv1beta3.NetworkPolicyAdmissionRule, len(manifests))
+	for i, m := range manifests {
+		if err := convertRule(m, &rules[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	manifests, err = readManifests(namespaceFiles, stdin)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]*corev1.Namespace, len(manifests))
+	for _, m := range manifests {
+		ns := &corev1.Namespace{}
+		if err := convert(m, ns); err != nil {
+			return nil, err
+		}
+		namespaces[ns.Name] = ns
+	}
+
+	manifests, err = readManifests(policyFiles, stdin)
+	if err != nil {
+		return nil, err
+	}
+	var evaluator policy.Evaluator
+	results := make([]checkedPolicy, 0, len(manifests))
+	for _, m := range manifests {
+		var ns *corev1.Namespace
+		switch m.GroupVersionKind() {
+		case cilium.CiliumNetworkPolicy().GroupVersionKind():
+			if m.GetNamespace() == "" {
+				m.SetNamespace(defaultNamespace)
+			}
+			ns = namespaces[m.GetNamespace()]
+			if ns == nil {
+				// like the webhook, evaluate policies in unknown namespaces as if the namespace had no labels.
+				ns = &corev1.Namespace{}
+				ns.Name = m.GetNamespace()
+			}
+		case cilium.CiliumClusterwideNetworkPolicy().GroupVersionKind():
+			fmt.Fprintf(stderr, "%s: %s %s: skipped, as the admission webhook does not evaluate CiliumClusterwideNetworkPolicies\n", m.source, m.GetKind(), m.GetName())
+			continue
+		default:
+			return nil, fmt.Errorf("%s: expected CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy, got %s", m.source, m.GroupVersionKind())
+		}
+
+		violations, err := evaluator.Evaluate(rules, ns, m.Unstructured)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s %s: %w", m.source, m.GetKind(), m.GetName(), err)
+		}
+		result := checkedPolicy{
+			File:       m.source,
+			Kind:       m.GetKind(),
+			Namespace:  m.GetNamespace(),
+			Name:       m.GetName(),
+			Violations: make([]checkedViolation, 0, len(violations)),
+		}
+		for _, v := range violations {
+			action := v.Rule.Spec.EnforcementAction
+			if action == "" {
+				action = tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionDeny
+			}
+			result.Violations = append(result.Violations, checkedViolation{
+				Rule:              v.Rule.Name,
+				EnforcementAction: string(action),
+				Section:           v.Section,
+				Kind:              v.Kind,
+				Value:             v.Value,
+				Forbidden:         v.Forbidden,
+				Allowed:           v.Allowed,
+				Protected:         v.Protected,
+				RequiredDeny:      v.RequiredDeny,
+				Path:              v.Path,
+				Message:           v.String(),
+			})
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func writeCheckText(w io.Writer, results []checkedPolicy) error {
+	var denied int
+	for _, r := range results {
+		if len(r.denials()) > 0 {
+			denied++
+		}
+		for _, v := range r.Violations {
+			level := "error"
+			if !v.denied() {
+				level = v.EnforcementAction
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s: %s: %s\n", r.File, r, level, v.Message); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d network policies checked, %d would be denied\n", len(results), denied)
+	return err
+}
+
+func writeCheckJSON(w io.Writer, results []checkedPolicy) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeCheckJUnit writes one test case per network policy, failing if the policy would be denied.
+// Violations of rules in warn or dryrun mode are written to the standard output of the test case.
+func writeCheckJUnit(w io.Writer, results []checkedPolicy) error {
+	suite := junitTestSuite{
+		Name:  "tenetctl check",
+		Tests: len(results),
+	}
+	for _, r := range results {
+		tc := junitTestCase{
+			ClassName: r.File,
+			Name:      r.String(),
+		}
+		for _, v := range r.Violations {
+			if v.denied() {
+				continue
+			}
+			tc.SystemOut += fmt.Sprintf("%s: %s\n", v.EnforcementAction, v.Message)
+		}
+		if denials := r.denials(); len(denials) > 0 {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("NetworkPolicyAdmissionRule violations: %d", len(denials)),
+				Type:    "NetworkPolicyAdmissionRuleViolation",
+			}
+			for _, v := range denials {
+				tc.Failure.Text += v.Message + "\n"
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
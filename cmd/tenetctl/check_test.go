@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestRunCheck(t *testing.T) {
+	testCommand(t, runCheck, []commandTest{
+		{
+			name:   "text",
+			args:   []string{"--rules", "testdata/rules.yaml", "--namespaces", "testdata/namespaces.yaml", "testdata/policies.yaml"},
+			code:   1,
+			stdout: "check.txt",
+		},
+		{
+			name:   "json",
+			args:   []string{"--rules", "testdata/rules.yaml", "--namespaces", "testdata/namespaces.yaml", "--output", "json", "testdata/policies.yaml"},
+			code:   1,
+			stdout: "check.json",
+		},
+		{
+			name:   "junit",
+			args:   []string{"--rules", "testdata/rules.yaml", "--namespaces", "testdata/namespaces.yaml", "--output", "junit", "testdata/policies.yaml"},
+			code:   1,
+			stdout: "check.xml",
+		},
+		{
+			name:  "warnings only",
+			args:  []string{"--rules", "testdata/rules.yaml", "-"},
+			stdin: "policies-warn.yaml",
+		},
+		{
+			name:   "clusterwide policies",
+			args:   []string{"--rules", "testdata/rules.yaml", "testdata/policies-clusterwide.yaml"},
+			stderr: "testdata/policies-clusterwide.yaml: CiliumClusterwideNetworkPolicy bmc: skipped, as the admission webhook does not evaluate CiliumClusterwideNetworkPolicies",
+		},
+		{
+			name:   "stdin given twice",
+			args:   []string{"--rules", "-", "-"},
+			stdin:  "rules.yaml",
+			code:   1,
+			stderr: `the standard input "-" can be given only once`,
+		},
+		{
+			name:   "not a policy",
+			args:   []string{"--rules", "testdata/rules.yaml", "testdata/namespaces.yaml"},
+			code:   1,
+			stderr: "expected CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy",
+		},
+		{
+			name:   "unknown output format",
+			args:   []string{"--rules", "testdata/rules.yaml", "--output", "yaml", "testdata/policies.yaml"},
+			code:   2,
+			stderr: `unknown output format "yaml"`,
+		},
+		{
+			name:   "no rules",
+			args:   []string{"testdata/policies.yaml"},
+			code:   2,
+			stderr: "Usage: tenetctl check",
+		},
+	})
+}
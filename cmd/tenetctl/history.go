@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+// runHistory lists the revisions recorded in the status of a template, prints one of them,
+// or prints the differences between two of them.
+func runHistory(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	templateFile := fs.String("template", "", "The file containing the NetworkPolicyTemplate, e.g. the output of kubectl get -o yaml.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tenetctl history --template FILE [REVISION [REVISION]]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *templateFile == "" || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	npt, err := loadTemplate(*templateFile, &stdinReader{r: stdin})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	revisions := templateRevisions(npt)
+
+	switch fs.NArg() {
+	case 0:
+		err = writeRevisions(stdout, npt, revisions)
+	case 1:
+		var rev *tenetv1beta3.NetworkPolicyTemplateRevision
+		rev, err = lookupRevision(revisions, fs.Arg(0))
+		if err == nil {
+			_, err = io.WriteString(stdout, rev.PolicyTemplate)
+		}
+	case 2:
+		err = writeRevisionDiff(stdout, revisions, fs.Arg(0), fs.Arg(1))
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// templateRevisions returns the revision history of the template.
+// The policy template of the spec comes first when the controller has not recorded it yet.
+func templateRevisions(npt *tenetv1beta3.NetworkPolicyTemplate) []tenetv1beta3.NetworkPolicyTemplateRevision {
+	revisions := npt.Status.Revisions
+	current := render.Revision(npt.Spec.PolicyTemplate)
+	if len(revisions) > 0 && revisions[0].Revision == current {
+		return revisions
+	}
+	return append([]tenetv1beta3.NetworkPolicyTemplateRevision{{
+		Revision:       current,
+		Generation:     npt.Generation,
+		PolicyTemplate: npt.Spec.PolicyTemplate,
+	}}, revisions...)
+}
+
+func lookupRevision(revisions []tenetv1beta3.NetworkPolicyTemplateRevision, revision string) (*tenetv1beta3.NetworkPolicyTemplateRevision, error) {
+	for i := range revisions {
+		if revisions[i].Revision == revision {
+			return &revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("revision %s is not in the history", revision)
+}
+
+func writeRevisions(w io.Writer, npt *tenetv1beta3.NetworkPolicyTemplate, revisions []tenetv1beta3.NetworkPolicyTemplateRevision) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tGENERATION\tCREATED\tSTATUS")
+	for i, rev := range revisions {
+		created := "-"
+		if !rev.CreationTimestamp.IsZero() {
+			created = rev.CreationTimestamp.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		var status string
+		switch {
+		case i == 0 && rev.CreationTimestamp.IsZero():
+			status = "not reconciled"
+		case rev.Revision == npt.Status.Rollout.Revision:
+			status = "current"
+		case rev.Revision == npt.Status.Rollout.PreviousRevision:
+			status = "previous"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", rev.Revision, rev.Generation, created, status)
+	}
+	return tw.Flush()
+}
+
+func writeRevisionDiff(w io.Writer, revisions []tenetv1beta3.NetworkPolicyTemplateRevision, from, to string) error {
+	a, err := lookupRevision(revisions, from)
+	if err != nil {
+		return err
+	}
+	b, err := lookupRevision(revisions, to)
+	if err != nil {
+		return err
+	}
+	return difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+		A:        splitLines(a.PolicyTemplate),
+		B:        splitLines(b.PolicyTemplate),
+		FromFile: "revision " + a.Revision,
+		ToFile:   "revision " + b.Revision,
+		Context:  3,
+	})
+}
+
+// splitLines splits text into lines keeping their line endings, without an empty last line.
+func splitLines(text string) []string {
+	return difflib.SplitLines(strings.TrimSuffix(text, "\n"))
+}
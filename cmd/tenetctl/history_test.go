@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestRunHistory(t *testing.T) {
+	testCommand(t, runHistory, []commandTest{
+		{
+			name:   "list",
+			args:   []string{"--template", "testdata/template-history.yaml"},
+			stdout: "history.txt",
+		},
+		{
+			name:   "revision",
+			args:   []string{"--template", "-", "f482ff0bbe"},
+			stdin:  "template-history.yaml",
+			stdout: "history-revision.txt",
+		},
+		{
+			name:   "diff",
+			args:   []string{"--template", "testdata/template-history.yaml", "f482ff0bbe", "b7c6bbbb3e"},
+			stdout: "history-diff.txt",
+		},
+		{
+			name:   "unknown revision",
+			args:   []string{"--template", "testdata/template-history.yaml", "0000000000"},
+			code:   1,
+			stderr: "revision 0000000000 is not in the history",
+		},
+		{
+			name:   "too many revisions",
+			args:   []string{"--template", "testdata/template-history.yaml", "f482ff0bbe", "b7c6bbbb3e", "0000000000"},
+			code:   2,
+			stderr: "Usage: tenetctl history",
+		},
+	})
+}
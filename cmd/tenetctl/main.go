@@ -0,0 +1,60 @@
+// tenetctl renders and checks tenet resources offline.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(tenetv1beta2.AddToScheme(scheme))
+	utilruntime.Must(tenetv1beta3.AddToScheme(scheme))
+}
+
+// commands maps subcommand names to their implementations.
+// A command returns the exit code of tenetctl.
+var commands = map[string]func(args []string, stdin io.Reader, stdout, stderr io.Writer) int{
+	"render":  runRender,
+	"check":   runCheck,
+	"history": runHistory,
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, `Usage: tenetctl <command> [flags]
+
+Commands:
+  render    Render the network policies a NetworkPolicyTemplate generates for namespaces
+  check     Check network policies against NetworkPolicyAdmissionRules
+  history   List and compare the revisions of a NetworkPolicyTemplate
+
+Run "tenetctl <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+			usage(os.Stdout)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	os.Exit(cmd(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+}
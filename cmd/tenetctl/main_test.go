@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// commandTest is a test case of a tenetctl command.
+type commandTest struct {
+	name string
+	args []string
+	// stdin is the file given as the standard input, if any.
+	stdin string
+	code  int
+	// stdout is the file containing the expected standard output, if it is checked.
+	stdout string
+	// stderr is a substring of the expected standard error, if it is checked.
+	stderr string
+}
+
+func testCommand(t *testing.T, cmd func(args []string, stdin io.Reader, stdout, stderr io.Writer) int, tests []commandTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdin io.Reader = strings.NewReader("")
+			if tt.stdin != "" {
+				f, err := os.Open(filepath.Join("testdata", tt.stdin))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				stdin = f
+			}
+			var stdout, stderr bytes.Buffer
+			if code := cmd(tt.args, stdin, &stdout, &stderr); code != tt.code {
+				t.Errorf("exit code = %d, want %d; stderr:\n%s", code, tt.code, stderr.String())
+			}
+			if tt.stdout != "" {
+				want, err := os.ReadFile(filepath.Join("testdata", tt.stdout))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stdout.String() != string(want) {
+					t.Errorf("unexpected stdout:\n%s\nwant:\n%s", stdout.String(), want)
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("stderr %q does not contain %q", stderr.String(), tt.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// manifest is an object read from a manifest file.
+type manifest struct {
+	*unstructured.Unstructured
+	// source is the file the object was read from.
+	source string
+}
+
+// stdinFile is the file name standing for the standard input.
+const stdinFile = "-"
+
+// stdinReader reads the standard input at most once, as it is exhausted by the first read.
+// It is shared by all the inputs of a command so that only one of them can be "-".
+type stdinReader struct {
+	r    io.Reader
+	read bool
+}
+
+func (s *stdinReader) readAll() ([]byte, error) {
+	if s.read {
+		return nil, fmt.Errorf("the standard input %q can be given only once", stdinFile)
+	}
+	s.read = true
+	return io.ReadAll(s.r)
+}
+
+// readManifests reads the objects of multi-document YAML or JSON files. "-" reads the standard input.
+// Lists, such as the output of kubectl get -o yaml, are expanded into their items.
+func readManifests(paths []string, stdin *stdinReader) ([]manifest, error) {
+	var manifests []manifest
+	for _, path := range paths {
+		var data []byte
+		var err error
+		if path == stdinFile {
+			data, err = stdin.readAll()
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), len(data))
+		for {
+			obj := &unstructured.Unstructured{}
+			err := y.Decode(&obj.Object)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			if !obj.IsList() {
+				manifests = append(manifests, manifest{Unstructured: obj, source: path})
+				continue
+			}
+			err = obj.EachListItem(func(item runtime.Object) error {
+				manifests = append(manifests, manifest{Unstructured: item.(*unstructured.Unstructured), source: path})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	return manifests, nil
+}
+
+// convert converts a manifest to a typed object, failing if its kind is not the kind of obj.
+func convert(m manifest, obj runtime.Object) error {
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil {
+		return err
+	}
+	if m.GroupVersionKind() != gvks[0] {
+		return fmt.Errorf("%s: expected %s, got %s", m.source, gvks[0].Kind, m.GroupVersionKind())
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m.Object, obj); err != nil {
+		return fmt.Errorf("%s: %w", m.source, err)
+	}
+	return nil
+}
+
+// writeYAML writes objects as a multi-document YAML stream.
+func writeYAML(w io.Writer, objs []*unstructured.Unstructured) error {
+	for i, obj := range objs {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		data, err := sigsyaml.Marshal(obj.Object)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+// runRender prints the network policies the controller would generate from a template for the given namespaces.
+func runRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	templateFile := fs.String("template", "", "The file containing the NetworkPolicyTemplate.")
+	var namespaceFiles []string
+	fs.Func("namespaces", "A file containing Namespaces. Can be repeated. \"-\" reads the standard input.", func(s string) error {
+		namespaceFiles = append(namespaceFiles, s)
+		return nil
+	})
+	ignoreOptIn := fs.Bool("ignore-opt-in", false, "Render the template for all namespaces, even those not opted into it.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tenetctl render --template FILE --namespaces FILE [--namespaces FILE...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *templateFile == "" || len(namespaceFiles) == 0 || fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	npt, namespaces, err := loadRenderInputs(*templateFile, namespaceFiles, &stdinReader{r: stdin})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var policies []*unstructured.Unstructured
+	failed := false
+	for _, ns := range namespaces {
+		if !*ignoreOptIn && !render.IsOptedIn(npt, ns) {
+			fmt.Fprintf(stderr, "namespace %s does not opt into NetworkPolicyTemplate %s; skipped\n", ns.Name, npt.Name)
+			continue
+		}
+		np, err := render.Render(npt, ns, scheme)
+		if err != nil {
+			fmt.Fprintf(stderr, "namespace %s: %v\n", ns.Name, err)
+			failed = true
+			continue
+		}
+		policies = append(policies, np)
+	}
+	if err := writeYAML(stdout, policies); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func loadRenderInputs(templateFile string, namespaceFiles []string, stdin *stdinReader) (*tenetv1beta3.NetworkPolicyTemplate, []*corev1.Namespace, error) {
+	npt, err := loadTemplate(templateFile, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifests, err := readManifests(namespaceFiles, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	namespaces := make([]*corev1.Namespace, 0, len(manifests))
+	for _, m := range manifests {
+		ns := &corev1.Namespace{}
+		if err := convert(m, ns); err != nil {
+			return nil, nil, err
+		}
+		namespaces = append(namespaces, ns)
+	}
+	return npt, namespaces, nil
+}
+
+// loadTemplate reads a file containing exactly one NetworkPolicyTemplate.
+// v1beta2 templates are converted to v1beta3.
+func loadTemplate(templateFile string, stdin *stdinReader) (*tenetv1beta3.NetworkPolicyTemplate, error) {
+	templates, err := readManifests([]string{templateFile}, stdin)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) != 1 {
+		return nil, fmt.Errorf("%s: expected one NetworkPolicyTemplate, got %d objects", templateFile, len(templates))
+	}
+	npt := &tenetv1beta3.NetworkPolicyTemplate{}
+	if templates[0].GroupVersionKind() == tenetv1beta2.GroupVersion.WithKind(tenetv1beta2.NetworkPolicyTemplateKind) {
+		legacy := &tenetv1beta2.NetworkPolicyTemplate{}
+		if err := convert(templates[0], legacy); err != nil {
+			return nil, err
+		}
+		if err := legacy.ConvertTo(npt); err != nil {
+			return nil, fmt.Errorf("%s: %w", templateFile, err)
+		}
+		return npt, nil
+	}
+	if err := convert(templates[0], npt); err != nil {
+		return nil, err
+	}
+	return npt, nil
+}
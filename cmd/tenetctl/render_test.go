@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestRunRender(t *testing.T) {
+	testCommand(t, runRender, []commandTest{
+		{
+			name:   "opted-in namespaces",
+			args:   []string{"--template", "testdata/template.yaml", "--namespaces", "testdata/namespaces.yaml"},
+			stdout: "render.yaml",
+			stderr: "namespace team-b does not opt into NetworkPolicyTemplate allow-intra-namespace-egress; skipped",
+		},
+		{
+			name:   "all namespaces",
+			args:   []string{"--template", "testdata/template.yaml", "--namespaces", "-", "--ignore-opt-in"},
+			stdin:  "namespaces.yaml",
+			stdout: "render-all.yaml",
+		},
+		{
+			name:   "v1beta2 template",
+			args:   []string{"--template", "testdata/template-v1beta2.yaml", "--namespaces", "testdata/namespaces.yaml"},
+			stdout: "render.yaml",
+		},
+		{
+			name:   "invalid template",
+			args:   []string{"--template", "testdata/template-invalid.yaml", "--namespaces", "testdata/namespaces.yaml"},
+			code:   1,
+			stderr: "namespace team-a: template: allow-intra-namespace-egress",
+		},
+		{
+			name:   "stdin given twice",
+			args:   []string{"--template", "-", "--namespaces", "-"},
+			stdin:  "template.yaml",
+			code:   1,
+			stderr: `the standard input "-" can be given only once`,
+		},
+		{
+			name:   "not a template",
+			args:   []string{"--template", "testdata/namespaces.yaml", "--namespaces", "testdata/namespaces.yaml"},
+			code:   1,
+			stderr: "expected one NetworkPolicyTemplate, got 2 objects",
+		},
+		{
+			name:   "no namespaces",
+			args:   []string{"--template", "testdata/template.yaml"},
+			code:   2,
+			stderr: "Usage: tenetctl render",
+		},
+	})
+}
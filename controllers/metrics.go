@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "tenet"
+
+// States of a namespace opted into a NetworkPolicyTemplate.
+const (
+	// templateStateApplied means the policy generated for the namespace is up to date.
+	templateStateApplied = "applied"
+	// templateStateInvalid means the template could not be rendered for the namespace.
+	templateStateInvalid = "invalid"
+	// templateStateFailed means the generated policy could not be created or updated.
+	templateStateFailed = "failed"
+	// templateStateDenied means the policy rendered for the namespace violates NetworkPolicyAdmissionRules and was not applied.
+	templateStateDenied = "denied"
+	// templateStatePending means the policy generated for the namespace waits for the rollout of the current revision.
+	templateStatePending = "pending"
+	// templateStatePaused means the reconciliation of the namespace is paused, and its policy is left as it is.
+	templateStatePaused = "paused"
+)
+
+var templateStates = []string{templateStateApplied, templateStateInvalid, templateStateFailed, templateStateDenied, templateStatePending, templateStatePaused}
+
+var (
+	templateNamespaces = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "template_namespaces",
+		Help:      "The number of namespaces opted into a NetworkPolicyTemplate by state.",
+	}, []string{"template", "state"})
+
+	renderErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "render_errors_total",
+		Help:      "The number of failures to render a NetworkPolicyTemplate for a namespace.",
+	}, []string{"template"})
+
+	generatedPolicies = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "generated_policies",
+		Help:      "The number of existing network policies owned by NetworkPolicyTemplates.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(templateNamespaces, renderErrorsTotal, generatedPolicies)
+}
+
+// generatedPolicyCounts tracks the number of policies generated from each template to compute generatedPolicies.
+var generatedPolicyCounts = struct {
+	sync.Mutex
+	counts map[string]int
+}{counts: make(map[string]int)}
+
+// setTemplateMetrics records the number of opted-in namespaces of the template by state,
+// and the number of existing policies generated from the template.
+func setTemplateMetrics(template string, counts map[string]int, generated int) {
+	for _, state := range templateStates {
+		templateNamespaces.WithLabelValues(template, state).Set(float64(counts[state]))
+	}
+	setGeneratedPolicies(template, generated)
+}
+
+// deleteTemplateMetrics removes the metrics of a deleted template.
+func deleteTemplateMetrics(template string) {
+	templateNamespaces.DeletePartialMatch(prometheus.Labels{"template": template})
+	renderErrorsTotal.DeleteLabelValues(template)
+	setGeneratedPolicies(template, 0)
+}
+
+func setGeneratedPolicies(template string, count int) {
+	generatedPolicyCounts.Lock()
+	defer generatedPolicyCounts.Unlock()
+
+	if count == 0 {
+		delete(generatedPolicyCounts.counts, template)
+	} else {
+		generatedPolicyCounts.counts[template] = count
+	}
+	var total int
+	for _, c := range generatedPolicyCounts.counts {
+		total += c
+	}
+	generatedPolicies.Set(float64(total))
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+)
+
+// NetworkPolicyAdmissionExceptionReconciler marks NetworkPolicyAdmissionExceptions as expired once they expire.
+// Marking an exception as expired triggers an audit of its rule, so that network policies relying on it are reported.
+type NetworkPolicyAdmissionExceptionReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionexceptions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionexceptions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// Reconcile requeues an active exception until it expires, then records the expiry in its status.
+func (r *NetworkPolicyAdmissionExceptionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	npae := &tenetv1beta2.NetworkPolicyAdmissionException{}
+	if err := r.Get(ctx, req.NamespacedName, npae); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !npae.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	now := v1.Now()
+	if npae.IsActive(now) {
+		if npae.Status.Expired {
+			// the expiry was extended.
+			npae.Status.Expired = false
+			if err := r.Status().Update(ctx, npae); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+			}
+		}
+		return ctrl.Result{RequeueAfter: npae.Spec.ExpiresAt.Sub(now.Time)}, nil
+	}
+	if npae.Status.Expired {
+		return ctrl.Result{}, nil
+	}
+
+	npae.Status.Expired = true
+	if err := r.Status().Update(ctx, npae); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+	}
+	r.Recorder.Eventf(npae, nil, corev1.EventTypeNormal, "Expired", "Expire",
+		"exception to NetworkPolicyAdmissionRule %s in namespace %s expired", npae.Spec.Rule, npae.Spec.Namespace)
+	logger.Info("exception expired", "rule", npae.Spec.Rule, "namespace", npae.Spec.Namespace)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NetworkPolicyAdmissionExceptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tenetv1beta2.NetworkPolicyAdmissionException{}).
+		Complete(r)
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+var _ = Describe("NetworkPolicyAdmissionException controller", func() {
+	ctx := context.Background()
+	var stopFunc func()
+
+	BeforeEach(func() {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:         scheme,
+			LeaderElection: false,
+			Metrics: metricsserver.Options{
+				BindAddress: "0",
+			},
+			Client: client.Options{
+				Cache: &client.CacheOptions{
+					Unstructured: true,
+				},
+			},
+			Controller: config.Controller{
+				SkipNameValidation: new(true),
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		nparr := &NetworkPolicyAdmissionRuleReconciler{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("NetworkPolicyAdmissionRule"),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      mgr.GetEventRecorder("tenet-controller"),
+			AuditInterval: time.Hour,
+		}
+		err = nparr.SetupWithManager(mgr)
+		Expect(err).NotTo(HaveOccurred())
+
+		npaer := &NetworkPolicyAdmissionExceptionReconciler{
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("NetworkPolicyAdmissionException"),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorder("tenet-controller"),
+		}
+		err = npaer.SetupWithManager(mgr)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(ctx)
+		stopFunc = cancel
+		go func() {
+			err := mgr.Start(ctx)
+			if err != nil {
+				panic(err)
+			}
+		}()
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	AfterEach(func() {
+		err := k8sClient.DeleteAllOf(ctx, &tenetv1beta2.NetworkPolicyAdmissionException{})
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.DeleteAllOf(ctx, &tenetv1beta3.NetworkPolicyAdmissionRule{})
+		Expect(err).NotTo(HaveOccurred())
+		stopFunc()
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	It("should reject exceptions with malformed CIDRs or unknown entities", func() {
+		for _, spec := range []tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+			{CIDR: "10.72.16.0/33"},
+			{CIDR: "bmc"},
+			{Entity: "everything"},
+		} {
+			spec.Namespace = "default"
+			spec.Rule = "forbid-bmc"
+			spec.Reason = "INC-1234"
+			spec.ExpiresAt = v1.NewTime(time.Now().Add(time.Hour))
+			npae := &tenetv1beta2.NetworkPolicyAdmissionException{
+				ObjectMeta: v1.ObjectMeta{Name: uuid.NewString()},
+				Spec:       spec,
+			}
+			err := k8sClient.Create(ctx, npae)
+			Expect(err).To(HaveOccurred(), "spec %+v", spec)
+		}
+	})
+
+	It("should audit policies relying on an exception once it expires", func() {
+		nsName := uuid.NewString()
+		shouldCreateNamespace(ctx, nsName, nil)
+		shouldCreateCiliumNetworkPolicy(ctx, nsName, forbiddenEgressCNP)
+
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.16.0/20",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		npae := &tenetv1beta2.NetworkPolicyAdmissionException{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: nsName,
+				Rule:      npar.Name,
+				CIDR:      "10.72.16.0/24",
+				ExpiresAt: v1.NewTime(time.Now().Add(5 * time.Second)),
+				Reason:    "test",
+			},
+		}
+		err := k8sClient.Create(ctx, npae)
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("checking the policy is not reported while the exception is active")
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.LastAuditTime).NotTo(BeNil())
+			g.Expect(current.Status.Violations).NotTo(ContainElement(HaveField("Namespace", nsName)))
+		}).Should(Succeed())
+
+		By("checking the exception expires and the policy is reported")
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta2.NetworkPolicyAdmissionException{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npae), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.Expired).To(BeTrue())
+		}).WithTimeout(10 * time.Second).Should(Succeed())
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.Violations).To(ContainElement(And(
+				HaveField("Namespace", nsName),
+				HaveField("Name", "forbidden-egress"),
+			)))
+		}).Should(Succeed())
+	})
+})
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/policy"
+)
+
+const (
+	// maxAuditViolations is the maximum number of violations recorded in the status of a NetworkPolicyAdmissionRule.
+	maxAuditViolations = 50
+)
+
+// NetworkPolicyAdmissionRuleReconciler audits existing network policies against a NetworkPolicyAdmissionRule.
+type NetworkPolicyAdmissionRuleReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+	// AuditInterval is the interval between periodic audits of a rule.
+	AuditInterval time.Duration
+}
+
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionrules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionrules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionexceptions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// Reconcile evaluates all CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies against the rule
+// and records the violations in the status of the rule and as events on the offending policies.
+func (r *NetworkPolicyAdmissionRuleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	npar := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+	if err := r.Get(ctx, req.NamespacedName, npar); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !npar.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	violations, err := r.audit(ctx, npar)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to audit network policies: %w", err)
+	}
+
+	now := v1.Now()
+	npar.Status.ObservedGeneration = npar.Generation
+	npar.Status.LastAuditTime = &now
+	npar.Status.ViolationCount = len(violations)
+	npar.Status.Violations = violations[:min(len(violations), maxAuditViolations)]
+	if err := r.Status().Update(ctx, npar); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+	}
+
+	logger.Info("done auditing", "violations", len(violations))
+	return ctrl.Result{RequeueAfter: r.AuditInterval}, nil
+}
+
+// audit returns the existing network policies violating the rule, and records events for violations
+// that are not listed in the status of the rule yet. As the status only lists the first maxAuditViolations
+// violations, events of the other violations are recorded again on every audit.
+func (r *NetworkPolicyAdmissionRuleReconciler) audit(ctx context.Context, npar *tenetv1beta3.NetworkPolicyAdmissionRule) ([]tenetv1beta3.NetworkPolicyAdmissionRuleViolation, error) {
+	pvs, err := policy.FindViolations(ctx, r, []tenetv1beta3.NetworkPolicyAdmissionRule{*npar})
+	if err != nil {
+		return nil, err
+	}
+
+	violations := make([]tenetv1beta3.NetworkPolicyAdmissionRuleViolation, 0, len(pvs))
+	for _, pv := range pvs {
+		np := pv.Policy
+		msg := r.summarize(pv.Violations)
+		violation := tenetv1beta3.NetworkPolicyAdmissionRuleViolation{
+			Kind:      np.GetKind(),
+			Namespace: np.GetNamespace(),
+			Name:      np.GetName(),
+			Message:   msg,
+		}
+		violations = append(violations, violation)
+		if slices.Contains(npar.Status.Violations, violation) {
+			continue
+		}
+		r.Recorder.Eventf(np, npar, corev1.EventTypeWarning, "AdmissionRuleViolation", "Audit",
+			"violates NetworkPolicyAdmissionRule %s: %s", npar.Name, msg)
+	}
+	return violations, nil
+}
+
+func (r *NetworkPolicyAdmissionRuleReconciler) summarize(vs []policy.Violation) string {
+	var msgs []string
+	for _, v := range vs {
+		msg := v.String()
+		if !slices.Contains(msgs, msg) {
+			msgs = append(msgs, msg)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// exceptionRule maps a NetworkPolicyAdmissionException to its rule so that the rule is audited again
+// when the exception changes or expires.
+func exceptionRule(_ context.Context, obj client.Object) []reconcile.Request {
+	npae, ok := obj.(*tenetv1beta2.NetworkPolicyAdmissionException)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: npae.Spec.Rule}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NetworkPolicyAdmissionRuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&tenetv1beta3.NetworkPolicyAdmissionRule{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&tenetv1beta2.NetworkPolicyAdmissionException{}, handler.EnqueueRequestsFromMapFunc(exceptionRule)).
+		Complete(r)
+}
@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+const (
+	forbiddenEgressCNP = `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+    name: forbidden-egress
+spec:
+    endpointSelector: {}
+    egress:
+    - toCIDR:
+        - 10.72.16.0/24
+`
+)
+
+func shouldCreateCiliumNetworkPolicy(ctx context.Context, nsName, manifest string) {
+	cnp := cilium.CiliumNetworkPolicy()
+	y := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), len(manifest))
+	err := y.Decode(cnp)
+	Expect(err).NotTo(HaveOccurred())
+	cnp.SetNamespace(nsName)
+	err = k8sClient.Create(ctx, cnp)
+	Expect(err).NotTo(HaveOccurred())
+}
+
+// countingRecorder counts the events recorded for each reason and object name.
+type countingRecorder struct {
+	events.EventRecorder
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (r *countingRecorder) Eventf(regarding runtime.Object, related runtime.Object, eventtype, reason, action, note string, args ...any) {
+	r.mu.Lock()
+	if obj, ok := regarding.(client.Object); ok {
+		r.counts[reason+"/"+obj.GetName()]++
+	}
+	r.mu.Unlock()
+	r.EventRecorder.Eventf(regarding, related, eventtype, reason, action, note, args...)
+}
+
+func (r *countingRecorder) count(reason, name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[reason+"/"+name]
+}
+
+var _ = Describe("NetworkPolicyAdmissionRule audit controller", func() {
+	ctx := context.Background()
+	var stopFunc func()
+	var recorder *countingRecorder
+
+	BeforeEach(func() {
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:         scheme,
+			LeaderElection: false,
+			Metrics: metricsserver.Options{
+				BindAddress: "0",
+			},
+			Client: client.Options{
+				Cache: &client.CacheOptions{
+					Unstructured: true,
+				},
+			},
+			Controller: config.Controller{
+				SkipNameValidation: new(true),
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		recorder = &countingRecorder{
+			EventRecorder: mgr.GetEventRecorder("tenet-controller"),
+			counts:        make(map[string]int),
+		}
+		nparr := &NetworkPolicyAdmissionRuleReconciler{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("NetworkPolicyAdmissionRule"),
+			Scheme:        mgr.GetScheme(),
+			Recorder:      recorder,
+			AuditInterval: time.Second,
+		}
+		err = nparr.SetupWithManager(mgr)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(ctx)
+		stopFunc = cancel
+		go func() {
+			err := mgr.Start(ctx)
+			if err != nil {
+				panic(err)
+			}
+		}()
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	AfterEach(func() {
+		err := k8sClient.DeleteAllOf(ctx, &tenetv1beta3.NetworkPolicyAdmissionRule{})
+		Expect(err).NotTo(HaveOccurred())
+		stopFunc()
+		time.Sleep(100 * time.Millisecond)
+	})
+
+	It("should record existing policies violating a rule", func() {
+		nsName := uuid.NewString()
+		shouldCreateNamespace(ctx, nsName, nil)
+		shouldCreateCiliumNetworkPolicy(ctx, nsName, forbiddenEgressCNP)
+
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "neco",
+					},
+				},
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.16.0/20",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.ObservedGeneration).To(Equal(current.Generation))
+			g.Expect(current.Status.Violations).To(ContainElement(And(
+				HaveField("Kind", "CiliumNetworkPolicy"),
+				HaveField("Namespace", nsName),
+				HaveField("Name", "forbidden-egress"),
+			)))
+		}).Should(Succeed())
+
+		By("auditing the rule again")
+		lastAuditTime := &v1.Time{}
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.LastAuditTime).NotTo(BeNil())
+			if lastAuditTime.IsZero() {
+				lastAuditTime = current.Status.LastAuditTime
+			}
+			g.Expect(current.Status.LastAuditTime.After(lastAuditTime.Time)).To(BeTrue())
+		}).Should(Succeed())
+		Expect(recorder.count("AdmissionRuleViolation", "forbidden-egress")).To(Equal(1))
+	})
+
+	It("should not record policies in excluded namespaces", func() {
+		nsName := uuid.NewString()
+		shouldCreateNamespace(ctx, nsName, nil)
+		ns := &corev1.Namespace{}
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: nsName}, ns)
+		Expect(err).NotTo(HaveOccurred())
+		ns.Labels = map[string]string{"team": "neco"}
+		err = k8sClient.Update(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		shouldCreateCiliumNetworkPolicy(ctx, nsName, forbiddenEgressCNP)
+
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "neco",
+					},
+				},
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.16.0/20",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err = k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.LastAuditTime).NotTo(BeNil())
+			g.Expect(current.Status.Violations).NotTo(ContainElement(HaveField("Namespace", nsName)))
+		}).Should(Succeed())
+	})
+})
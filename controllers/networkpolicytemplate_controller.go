@@ -17,49 +17,70 @@ limitations under the License.
 package controllers
 
 import (
-	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"slices"
 	"strings"
-	"text/template"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/policy"
+	"github.com/cybozu-go/tenet/pkg/render"
 	"github.com/cybozu-go/tenet/pkg/tenet"
 )
 
 const (
 	finalizerName = "tenet.cybozu.io/finalizer"
+
+	// maxAdmissionDenials is the maximum number of namespaces listed in the admission denials of a NetworkPolicyTemplate.
+	maxAdmissionDenials = 50
 )
 
 // NetworkPolicyTemplateReconciler reconciles a NetworkPolicyTemplate object.
 type NetworkPolicyTemplateReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	evaluator policy.Evaluator
+}
+
+// admissionCheck holds what is needed to evaluate rendered policies the way the admission webhook does.
+type admissionCheck struct {
+	rules      []tenetv1beta3.NetworkPolicyAdmissionRule
+	exceptions []tenetv1beta2.NetworkPolicyAdmissionException
+	now        v1.Time
 }
 
 //+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicytemplates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicytemplates/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicytemplates/finalizers,verbs=update
-// +kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionrules,verbs=get;list
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionrules,verbs=get;list;watch
+//+kubebuilder:rbac:groups=tenet.cybozu.io,resources=networkpolicyadmissionexceptions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 //+kubebuilder:rbac:groups="cilium.io",resources=ciliumnetworkpolicies,verbs=get;list;watch;create;update;delete
 //+kubebuilder:rbac:groups="cilium.io",resources=ciliumclusterwidenetworkpolicies,verbs=get;list;watch;create;update;delete
@@ -72,8 +93,11 @@ type NetworkPolicyTemplateReconciler struct {
 func (r *NetworkPolicyTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
-	npt := &tenetv1beta2.NetworkPolicyTemplate{}
+	npt := &tenetv1beta3.NetworkPolicyTemplate{}
 	if err := r.Get(ctx, req.NamespacedName, npt); err != nil {
+		if apierrors.IsNotFound(err) {
+			deleteTemplateMetrics(req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
@@ -93,19 +117,42 @@ func (r *NetworkPolicyTemplateReconciler) Reconcile(ctx context.Context, req ctr
 		return ctrl.Result{}, nil
 	}
 
+	if _, ok := npt.Annotations[tenet.RollbackAnnotation]; ok {
+		return ctrl.Result{}, r.rollback(ctx, npt)
+	}
 	return r.reconcileTemplate(ctx, npt)
 }
 
-func (r *NetworkPolicyTemplateReconciler) shouldDelete(npt *tenetv1beta2.NetworkPolicyTemplate, ownerRefs []v1.OwnerReference) bool {
+// rollback restores the policy template of the previous revision from the revision history and removes the rollback annotation.
+// The rollout of the restored revision then updates all namespaces at once.
+func (r *NetworkPolicyTemplateReconciler) rollback(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate) error {
+	logger := log.FromContext(ctx)
+	delete(npt.Annotations, tenet.RollbackAnnotation)
+	status := npt.Status.Rollout
+	previous := findRevision(npt, status.PreviousRevision)
+	if previous == nil {
+		r.Recorder.Eventf(npt, nil, corev1.EventTypeWarning, "RollbackUnavailable", "Rollback", "no previous revision to roll back to")
+		return r.Update(ctx, npt)
+	}
+	npt.Spec.PolicyTemplate = previous.PolicyTemplate
+	if err := r.Update(ctx, npt); err != nil {
+		return err
+	}
+	logger.Info("rolled back", "from", status.Revision, "to", status.PreviousRevision)
+	r.Recorder.Eventf(npt, nil, corev1.EventTypeNormal, "RolledBack", "Rollback", "rolled back from revision %s to %s", status.Revision, status.PreviousRevision)
+	return nil
+}
+
+func (r *NetworkPolicyTemplateReconciler) shouldDelete(npt *tenetv1beta3.NetworkPolicyTemplate, ownerRefs []v1.OwnerReference) bool {
 	for _, ownerRef := range ownerRefs {
-		if ownerRef.APIVersion == tenetv1beta2.GroupVersion.String() && ownerRef.Kind == tenetv1beta2.NetworkPolicyTemplateKind && ownerRef.Name == npt.Name {
+		if render.IsTemplateOwner(ownerRef) && ownerRef.Name == npt.Name {
 			return true
 		}
 	}
 	return false
 }
 
-func (r *NetworkPolicyTemplateReconciler) finalize(ctx context.Context, npt *tenetv1beta2.NetworkPolicyTemplate) error {
+func (r *NetworkPolicyTemplateReconciler) finalize(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate) error {
 	if !controllerutil.ContainsFinalizer(npt, finalizerName) {
 		return nil
 	}
@@ -121,6 +168,16 @@ func (r *NetworkPolicyTemplateReconciler) finalize(ctx context.Context, npt *ten
 	if err := r.List(ctx, npl); client.IgnoreNotFound(err) != nil {
 		return err
 	}
+	nsl := &corev1.NamespaceList{}
+	if err := r.List(ctx, nsl); err != nil {
+		return err
+	}
+	// namespaces maps the keys of the policies generated for namespaces, including clusterwide ones, to the namespaces.
+	namespaces := make(map[types.NamespacedName]*corev1.Namespace, len(nsl.Items))
+	for i := range nsl.Items {
+		namespaces[render.PolicyKey(npt, &nsl.Items[i])] = &nsl.Items[i]
+	}
+	var paused []tenetv1beta3.NetworkPolicyTemplatePausedNamespace
 	for _, np := range npl.Items {
 		if np.GetDeletionTimestamp() != nil {
 			continue
@@ -128,126 +185,297 @@ func (r *NetworkPolicyTemplateReconciler) finalize(ctx context.Context, npt *ten
 		if !r.shouldDelete(npt, np.GetOwnerReferences()) {
 			continue
 		}
+		// paused policies are kept, and so is the finalizer, so that the garbage collector does not delete them either.
+		key := types.NamespacedName{Namespace: np.GetNamespace(), Name: np.GetName()}
+		if p := pauseReason(npt, namespaces[key]); p != nil {
+			logger.Info("kept paused NetworkPolicy", "name", np.GetName(), "namespace", np.GetNamespace(), "kind", np.GetKind(), "reason", p.Reason)
+			paused = append(paused, *p)
+			continue
+		}
 		if err := r.Delete(ctx, &np); err != nil {
 			return fmt.Errorf("failed to delete %s %s: %w", np.GetKind(), np.GetName(), err)
 		}
 		logger.Info("deleted NetworkPolicy", "name", np.GetName(), "kind", np.GetKind())
 	}
+	if len(paused) > 0 {
+		return r.pauseFinalization(ctx, npt, paused)
+	}
 
+	deleteTemplateMetrics(npt.Name)
 	controllerutil.RemoveFinalizer(npt, finalizerName)
 	return r.Update(ctx, npt)
 }
 
-func (r *NetworkPolicyTemplateReconciler) reconcileTemplate(ctx context.Context, npt *tenetv1beta2.NetworkPolicyTemplate) (ctrl.Result, error) {
+func (r *NetworkPolicyTemplateReconciler) reconcileTemplate(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
-	npt.Status = tenetv1beta2.NetworkPolicyTemplateOK
+	previousDenials := npt.Status.AdmissionDenials
+	npt.Status.State = tenetv1beta3.NetworkPolicyTemplateOK
+	npt.Status.AdmissionDenials = nil
+	npt.Status.PausedNamespaces = nil
 
 	nsl := &corev1.NamespaceList{}
 	if err := r.List(ctx, nsl); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	check, err := r.newAdmissionCheck(ctx, npt)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	now := v1.Now()
+	recordRevision(npt, now)
+	ro, err := newRollout(npt, now)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("invalid rollout strategy: %w", err)
+	}
+	// canary namespaces come first so that the rollout knows whether batches must wait for them.
+	slices.SortFunc(nsl.Items, func(a, b corev1.Namespace) int {
+		if ca, cb := ro.isCanary(&a), ro.isCanary(&b); ca != cb {
+			if ca {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	counts := make(map[string]int)
 	for _, ns := range nsl.Items {
-		if err := r.reconcileNetworkPolicy(ctx, npt, ns); err != nil {
+		state, err := r.reconcileNetworkPolicy(ctx, npt, ns, check, ro)
+		if err != nil {
 			logger.Error(err, "failed to reconcile namespace", "name", ns.Name)
 		}
+		if state != "" {
+			counts[state]++
+		}
+	}
+	generated, err := r.countGeneratedPolicies(ctx, npt)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to count generated policies: %w", err)
 	}
+	setTemplateMetrics(npt.Name, counts, generated)
+	r.setAdmissionDenied(npt, previousDenials)
+	setPaused(npt)
+	wait := ro.finish(counts[templateStateApplied], counts[templateStatePending])
 
+	npt.Status.ObservedGeneration = npt.Generation
 	if err := r.Status().Update(ctx, npt); err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to reconcile template: %w", err)
 	}
 
 	logger.Info("done reconciling")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: wait}, nil
 }
 
-func (r *NetworkPolicyTemplateReconciler) reconcileNetworkPolicy(ctx context.Context, npt *tenetv1beta2.NetworkPolicyTemplate, ns corev1.Namespace) error {
-	logger := log.FromContext(ctx)
+// countGeneratedPolicies returns the number of existing policies owned by the template,
+// including the policies of paused namespaces and the policies left as they are when their update is denied.
+func (r *NetworkPolicyTemplateReconciler) countGeneratedPolicies(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate) (int, error) {
+	var npl *unstructured.UnstructuredList
+	if npt.Spec.ClusterWide {
+		npl = cilium.CiliumClusterwideNetworkPolicyList()
+	} else {
+		npl = cilium.CiliumNetworkPolicyList()
+	}
+	if err := r.List(ctx, npl); client.IgnoreNotFound(err) != nil {
+		return 0, err
+	}
+	var count int
+	for _, np := range npl.Items {
+		if np.GetDeletionTimestamp() == nil && r.shouldDelete(npt, np.GetOwnerReferences()) {
+			count++
+		}
+	}
+	return count, nil
+}
 
-	var existingNetworkPolicy *unstructured.Unstructured
-	var existingNetworkPolicyObjectKey types.NamespacedName
+// newAdmissionCheck lists the rules and exceptions rendered policies are evaluated against.
+// It returns nil for clusterwide templates, as the admission webhook does not evaluate CiliumClusterwideNetworkPolicies.
+func (r *NetworkPolicyTemplateReconciler) newAdmissionCheck(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate) (*admissionCheck, error) {
 	if npt.Spec.ClusterWide {
-		existingNetworkPolicy = cilium.CiliumClusterwideNetworkPolicy()
-		existingNetworkPolicyObjectKey = client.ObjectKey{
-			Name: fmt.Sprintf("%s-%s", ns.Name, npt.Name),
+		return nil, nil
+	}
+	var nparl tenetv1beta3.NetworkPolicyAdmissionRuleList
+	if err := r.List(ctx, &nparl); err != nil {
+		return nil, err
+	}
+	var npael tenetv1beta2.NetworkPolicyAdmissionExceptionList
+	if err := r.List(ctx, &npael); err != nil {
+		return nil, err
+	}
+	return &admissionCheck{rules: nparl.Items, exceptions: npael.Items, now: v1.Now()}, nil
+}
+
+// checkAdmission evaluates the rendered policy and returns the violations that would make the admission webhook deny it.
+func (r *NetworkPolicyTemplateReconciler) checkAdmission(check *admissionCheck, ns *corev1.Namespace, np *unstructured.Unstructured) ([]policy.Violation, error) {
+	if check == nil {
+		return nil, nil
+	}
+	violations, err := r.evaluator.Evaluate(check.rules, ns, np)
+	if err != nil {
+		return nil, err
+	}
+	violations, _ = policy.Exempt(check.exceptions, ns.Name, violations, check.now)
+	return slices.DeleteFunc(violations, func(v policy.Violation) bool { return !v.Denies() }), nil
+}
+
+// addAdmissionDenial records in the status that the policy rendered for the namespace is denied by the violations.
+func addAdmissionDenial(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace, violations []policy.Violation) {
+	denial := tenetv1beta3.NetworkPolicyTemplateAdmissionDenial{Namespace: ns.Name}
+	messages := make([]string, 0, len(violations))
+	for _, v := range violations {
+		if !slices.Contains(denial.Rules, v.Rule.Name) {
+			denial.Rules = append(denial.Rules, v.Rule.Name)
 		}
-	} else {
-		existingNetworkPolicy = cilium.CiliumNetworkPolicy()
-		existingNetworkPolicyObjectKey = client.ObjectKey{
-			Namespace: ns.Name,
-			Name:      npt.Name,
+		messages = append(messages, v.String())
+	}
+	slices.Sort(denial.Rules)
+	denial.Message = strings.Join(messages, "; ")
+	npt.Status.AdmissionDenials = append(npt.Status.AdmissionDenials, denial)
+}
+
+// setAdmissionDenied sets the AdmissionDenied condition from the admission denials found during the reconciliation,
+// and records events for denials that were not reported before.
+func (r *NetworkPolicyTemplateReconciler) setAdmissionDenied(npt *tenetv1beta3.NetworkPolicyTemplate, previous []tenetv1beta3.NetworkPolicyTemplateAdmissionDenial) {
+	denials := npt.Status.AdmissionDenials
+	slices.SortFunc(denials, func(a, b tenetv1beta3.NetworkPolicyTemplateAdmissionDenial) int {
+		return cmp.Compare(a.Namespace, b.Namespace)
+	})
+	for _, denial := range denials {
+		if slices.ContainsFunc(previous, func(p tenetv1beta3.NetworkPolicyTemplateAdmissionDenial) bool {
+			return p.Namespace == denial.Namespace && p.Message == denial.Message
+		}) {
+			continue
 		}
+		ns := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: denial.Namespace}}
+		r.Recorder.Eventf(npt, ns, corev1.EventTypeWarning, "AdmissionDenied", "Render",
+			"the policy rendered for namespace %s violates NetworkPolicyAdmissionRules: %s", denial.Namespace, denial.Message)
+		r.Recorder.Eventf(ns, npt, corev1.EventTypeWarning, "AdmissionDenied", "Render",
+			"the policy rendered from NetworkPolicyTemplate %s violates NetworkPolicyAdmissionRules: %s", npt.Name, denial.Message)
 	}
 
+	condition := v1.Condition{
+		Type:               tenetv1beta3.NetworkPolicyTemplateAdmissionDenied,
+		Status:             v1.ConditionFalse,
+		Reason:             "Admitted",
+		Message:            "policies rendered for all opted-in namespaces comply with NetworkPolicyAdmissionRules",
+		ObservedGeneration: npt.Generation,
+	}
+	if len(denials) > 0 {
+		namespaces := make([]string, 0, len(denials))
+		for _, denial := range denials {
+			namespaces = append(namespaces, fmt.Sprintf("%s (%s)", denial.Namespace, strings.Join(denial.Rules, ", ")))
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = "RuleViolation"
+		condition.Message = fmt.Sprintf("policies rendered for %d namespaces violate NetworkPolicyAdmissionRules: %s",
+			len(denials), strings.Join(namespaces[:min(len(namespaces), maxAdmissionDenials)], ", "))
+	}
+	meta.SetStatusCondition(&npt.Status.Conditions, condition)
+	npt.Status.AdmissionDenials = denials[:min(len(denials), maxAdmissionDenials)]
+}
+
+// reconcileNetworkPolicy creates, updates or deletes the policy generated from the template for the namespace.
+// Rendered policies denied by the admission check are recorded in the status of the template instead of being applied,
+// and policies rendered from another revision are only updated when the rollout admits the namespace.
+// Policies of paused namespaces are left as they are.
+// It returns the state of the namespace for the template, or an empty string if the namespace is not opted into it.
+func (r *NetworkPolicyTemplateReconciler) reconcileNetworkPolicy(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate, ns corev1.Namespace, check *admissionCheck, ro *rollout) (string, error) {
+	logger := log.FromContext(ctx)
+
+	existingNetworkPolicy := render.NewPolicy(npt)
+	existingNetworkPolicyObjectKey := render.PolicyKey(npt, &ns)
+	optedIn := render.IsOptedIn(npt, &ns)
 	existingNetworkPolicyError := r.Get(ctx, existingNetworkPolicyObjectKey, existingNetworkPolicy)
 	if client.IgnoreNotFound(existingNetworkPolicyError) != nil {
-		return existingNetworkPolicyError
+		if optedIn {
+			return templateStateFailed, existingNetworkPolicyError
+		}
+		return "", existingNetworkPolicyError
+	}
+
+	// paused policies are neither created, updated nor deleted.
+	if paused := pauseReason(npt, &ns); paused != nil {
+		if !optedIn && apierrors.IsNotFound(existingNetworkPolicyError) {
+			return "", nil
+		}
+		npt.Status.PausedNamespaces = append(npt.Status.PausedNamespaces, *paused)
+		if existingNetworkPolicyError == nil {
+			ro.pause(&ns, existingNetworkPolicy.GetAnnotations()[tenet.RevisionAnnotation])
+		}
+		return templateStatePaused, nil
 	}
 
 	// delete networkpolicy if the namespace no longer opts-in to it
-	if !r.isOptedIntoTemplate(npt, ns) {
+	if !optedIn {
 		if apierrors.IsNotFound(existingNetworkPolicyError) {
-			return nil
+			return "", nil
 		}
-		return r.Delete(ctx, existingNetworkPolicy)
+		return "", r.Delete(ctx, existingNetworkPolicy)
 	}
 
-	currentNetworkPolicy, err := r.compileTemplate(npt, ns)
+	currentNetworkPolicy, err := render.Render(npt, &ns, r.Scheme)
 	if err != nil {
-		npt.Status = tenetv1beta2.NetworkPolicyTemplateInvalid
+		npt.Status.State = tenetv1beta3.NetworkPolicyTemplateInvalid
+		renderErrorsTotal.WithLabelValues(npt.Name).Inc()
 		logger.Error(err, "invalid template", "name", npt.Name)
-		return err
+		return templateStateInvalid, err
+	}
+	violations, err := r.checkAdmission(check, &ns, currentNetworkPolicy)
+	if err != nil {
+		npt.Status.State = tenetv1beta3.NetworkPolicyTemplateInvalid
+		renderErrorsTotal.WithLabelValues(npt.Name).Inc()
+		logger.Error(err, "invalid template", "name", npt.Name)
+		return templateStateInvalid, err
+	}
+	if len(violations) > 0 {
+		addAdmissionDenial(npt, &ns, violations)
+		logger.Info("NetworkPolicy denied by NetworkPolicyAdmissionRules", "name", currentNetworkPolicy.GetName(), "namespace", ns.Name)
+		return templateStateDenied, nil
 	}
 	if apierrors.IsNotFound(existingNetworkPolicyError) {
 		logger.Info("creating NetworkPolicy", "name", currentNetworkPolicy.GetName(), "kind", currentNetworkPolicy.GetKind())
-		return r.Create(ctx, currentNetworkPolicy)
-	}
-	if equality.Semantic.DeepEqual(existingNetworkPolicy.UnstructuredContent()["spec"], currentNetworkPolicy.UnstructuredContent()["spec"]) {
-		return nil
+		if err := r.Create(ctx, currentNetworkPolicy); err != nil {
+			return templateStateFailed, err
+		}
+		return templateStateApplied, nil
 	}
-	existingNetworkPolicy.UnstructuredContent()["spec"] = currentNetworkPolicy.DeepCopy().UnstructuredContent()["spec"]
-	logger.Info("updating NetworkPolicy", "name", existingNetworkPolicy.GetName(), "kind", currentNetworkPolicy.GetKind())
-	return r.Update(ctx, existingNetworkPolicy)
-}
 
-func (r *NetworkPolicyTemplateReconciler) isOptedIntoTemplate(npt *tenetv1beta2.NetworkPolicyTemplate, ns corev1.Namespace) bool {
-	return slices.Contains(strings.Split(ns.Annotations[tenet.PolicyAnnotation], ","), npt.Name)
-}
-
-func (r *NetworkPolicyTemplateReconciler) compileTemplate(npt *tenetv1beta2.NetworkPolicyTemplate, ns corev1.Namespace) (*unstructured.Unstructured, error) {
-	var np, refNP *unstructured.Unstructured
-	if npt.Spec.ClusterWide {
-		np = cilium.CiliumClusterwideNetworkPolicy()
-		refNP = cilium.CiliumClusterwideNetworkPolicy()
-	} else {
-		np = cilium.CiliumNetworkPolicy()
-		refNP = cilium.CiliumNetworkPolicy()
+	revision := currentNetworkPolicy.GetAnnotations()[tenet.RevisionAnnotation]
+	sameRevision := existingNetworkPolicy.GetAnnotations()[tenet.RevisionAnnotation] == revision
+	sameSpec := equality.Semantic.DeepEqual(existingNetworkPolicy.UnstructuredContent()["spec"], currentNetworkPolicy.UnstructuredContent()["spec"])
+	if sameRevision && sameSpec {
+		return templateStateApplied, nil
 	}
-	tpl, err := template.New(npt.Name).Parse(npt.Spec.PolicyTemplate)
-	if err != nil {
-		return nil, err
+	// policies of other revisions rendering differently are updated as the rollout allows.
+	if !sameRevision && !sameSpec && !ro.admit(&ns) {
+		return templateStatePending, nil
 	}
-	var buf bytes.Buffer
-	if err := tpl.Execute(&buf, ns.ObjectMeta); err != nil {
-		return nil, err
-	}
-	y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buf.Bytes()), buf.Len())
-	if err := y.Decode(np); err != nil {
-		return nil, err
+	existingNetworkPolicy.UnstructuredContent()["spec"] = currentNetworkPolicy.DeepCopy().UnstructuredContent()["spec"]
+	annotations := existingNetworkPolicy.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
 	}
-	if np.GetAPIVersion() != refNP.GetAPIVersion() || np.GetKind() != refNP.GetKind() {
-		return nil, fmt.Errorf("invalid schema: %v", np.GetObjectKind().GroupVersionKind())
+	annotations[tenet.RevisionAnnotation] = revision
+	existingNetworkPolicy.SetAnnotations(annotations)
+	logger.Info("updating NetworkPolicy", "name", existingNetworkPolicy.GetName(), "kind", currentNetworkPolicy.GetKind(), "revision", revision)
+	if err := r.Update(ctx, existingNetworkPolicy); err != nil {
+		return templateStateFailed, err
 	}
+	return templateStateApplied, nil
+}
 
-	if npt.Spec.ClusterWide {
-		np.SetName(fmt.Sprintf("%s-%s", ns.Name, npt.Name))
-	} else {
-		np.SetNamespace(ns.Name)
-		np.SetName(npt.Name)
-	}
-	if err := controllerutil.SetOwnerReference(npt, np, r.Scheme); err != nil {
-		return nil, err
-	}
-	return np, nil
+// exceptionExpiryChanged passes updates of NetworkPolicyAdmissionExceptions recording or clearing their expiry,
+// as expired exceptions no longer apply to the admission check.
+var exceptionExpiryChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNPAE, ok := e.ObjectOld.(*tenetv1beta2.NetworkPolicyAdmissionException)
+		if !ok {
+			return false
+		}
+		newNPAE, ok := e.ObjectNew.(*tenetv1beta2.NetworkPolicyAdmissionException)
+		if !ok {
+			return false
+		}
+		return oldNPAE.Status.Expired != newNPAE.Status.Expired
+	},
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -260,14 +488,14 @@ func (r *NetworkPolicyTemplateReconciler) SetupWithManager(ctx context.Context,
 		}
 		owners := t.GetOwnerReferences()
 		for _, owner := range owners {
-			if owner.APIVersion == tenetv1beta2.GroupVersion.String() && owner.Kind == tenetv1beta2.NetworkPolicyTemplateKind {
+			if render.IsTemplateOwner(owner) {
 				return []string{owner.Name}
 			}
 		}
 		return nil
 	}
 	listNPTs := func(ctx context.Context, _ client.Object) []reconcile.Request {
-		var nptl tenetv1beta2.NetworkPolicyTemplateList
+		var nptl tenetv1beta3.NetworkPolicyTemplateList
 		if err := r.List(ctx, &nptl); err != nil {
 			r.Log.Error(err, "failed to list NetworkPolicyTemplates")
 			return nil
@@ -297,8 +525,11 @@ func (r *NetworkPolicyTemplateReconciler) SetupWithManager(ctx context.Context,
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&tenetv1beta2.NetworkPolicyTemplate{}).
+		For(&tenetv1beta3.NetworkPolicyTemplate{}).
 		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(listNPTs)).
+		// The status of rules is updated by every audit, so only their spec changes re-run the admission check.
+		Watches(&tenetv1beta3.NetworkPolicyAdmissionRule{}, handler.EnqueueRequestsFromMapFunc(listNPTs), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&tenetv1beta2.NetworkPolicyAdmissionException{}, handler.EnqueueRequestsFromMapFunc(listNPTs), builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, exceptionExpiryChanged))).
 		Watches(cilium.CiliumNetworkPolicy(), handler.EnqueueRequestsFromMapFunc(filterCNP)).
 		Watches(cilium.CiliumClusterwideNetworkPolicy(), handler.EnqueueRequestsFromMapFunc(filterCCNP)).
 		Complete(r)
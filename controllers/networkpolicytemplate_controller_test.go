@@ -6,14 +6,19 @@ import (
 	"strings"
 	"time"
 
-	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/render"
 	"github.com/cybozu-go/tenet/pkg/tenet"
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -66,6 +71,15 @@ spec:
         - cidr: 10.72.16.0/20
         - cidr: 10.76.16.0/20
         - cidr: 10.78.16.0/20
+`
+	bmcEgressTemplate = `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+spec:
+    endpointSelector: {}
+    egress:
+    - toCIDR:
+        - 10.72.16.0/24
 `
 	invalidTemplate = `
 apiVersion: networking.k8s.io/v1
@@ -78,12 +92,12 @@ spec:
 `
 )
 
-func newDummyNetworkPolicyTemplate(o client.ObjectKey, tmpl string) *tenetv1beta2.NetworkPolicyTemplate {
-	return &tenetv1beta2.NetworkPolicyTemplate{
+func newDummyNetworkPolicyTemplate(o client.ObjectKey, tmpl string) *tenetv1beta3.NetworkPolicyTemplate {
+	return &tenetv1beta3.NetworkPolicyTemplate{
 		ObjectMeta: v1.ObjectMeta{
 			Name: o.Name,
 		},
-		Spec: tenetv1beta2.NetworkPolicyTemplateSpec{
+		Spec: tenetv1beta3.NetworkPolicyTemplateSpec{
 			PolicyTemplate: tmpl,
 		},
 	}
@@ -107,6 +121,26 @@ func shouldCreateNamespace(ctx context.Context, nsName string, npts []string) {
 	Expect(err).NotTo(HaveOccurred())
 }
 
+// hasTemplateMetrics reports whether templateNamespaces has series for the template.
+func hasTemplateMetrics(template string) bool {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		templateNamespaces.Collect(ch)
+		close(ch)
+	}()
+	found := false
+	for m := range ch {
+		pb := &dto.Metric{}
+		Expect(m.Write(pb)).To(Succeed())
+		for _, label := range pb.GetLabel() {
+			if label.GetName() == "template" && label.GetValue() == template {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
 var _ = Describe("Tenet controller", func() {
 	ctx := context.Background()
 	var stopFunc func()
@@ -130,9 +164,10 @@ var _ = Describe("Tenet controller", func() {
 		Expect(err).NotTo(HaveOccurred())
 
 		nptr := &NetworkPolicyTemplateReconciler{
-			Client: mgr.GetClient(),
-			Log:    ctrl.Log.WithName("controllers").WithName("NetworkPolicyTemplate"),
-			Scheme: mgr.GetScheme(),
+			Client:   mgr.GetClient(),
+			Log:      ctrl.Log.WithName("controllers").WithName("NetworkPolicyTemplate"),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorder("tenet-controller"),
 		}
 		err = nptr.SetupWithManager(ctx, mgr)
 		Expect(err).NotTo(HaveOccurred())
@@ -176,15 +211,15 @@ var _ = Describe("Tenet controller", func() {
 		Expect(err).NotTo(HaveOccurred())
 		Expect(equality.Semantic.DeepEqual(cnp.UnstructuredContent()["spec"], expectedCNP.UnstructuredContent()["spec"])).To(BeTrue())
 
-		Eventually(func() tenetv1beta2.NetworkPolicyTemplateStatus {
-			npt := &tenetv1beta2.NetworkPolicyTemplate{}
+		Eventually(func() tenetv1beta3.NetworkPolicyTemplateState {
+			npt := &tenetv1beta3.NetworkPolicyTemplate{}
 			nptKey := client.ObjectKey{
 				Name: nptName,
 			}
 			err := k8sClient.Get(ctx, nptKey, npt)
 			Expect(err).NotTo(HaveOccurred())
-			return npt.Status
-		}).Should(Equal(tenetv1beta2.NetworkPolicyTemplateOK))
+			return npt.Status.State
+		}).Should(Equal(tenetv1beta3.NetworkPolicyTemplateOK))
 	})
 
 	It("should leave opted-out namespaces alone", func() {
@@ -271,7 +306,7 @@ var _ = Describe("Tenet controller", func() {
 			return k8sClient.Get(ctx, key, cnp)
 		}).Should(Succeed())
 
-		npt := &tenetv1beta2.NetworkPolicyTemplate{}
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
 		nptKey := client.ObjectKey{
 			Name: nptName,
 		}
@@ -301,6 +336,169 @@ var _ = Describe("Tenet controller", func() {
 		}).Should(Succeed())
 	})
 
+	It("should roll out template updates progressively and roll them back", func() {
+		nptName := uuid.NewString()
+		canaryNsName := uuid.NewString()
+		nsNames := []string{uuid.NewString(), uuid.NewString()}
+		npt := newDummyNetworkPolicyTemplate(client.ObjectKey{Name: nptName}, intraNSTemplate)
+		npt.Spec.Rollout = &tenetv1beta3.NetworkPolicyTemplateRolloutStrategy{
+			CanarySelector: &v1.LabelSelector{MatchLabels: map[string]string{"canary": nptName}},
+			BatchSize:      1,
+			BatchInterval:  v1.Duration{Duration: time.Hour},
+		}
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		canaryNs := &corev1.Namespace{}
+		canaryNs.Name = canaryNsName
+		canaryNs.Labels = map[string]string{"canary": nptName}
+		canaryNs.Annotations = map[string]string{tenet.PolicyAnnotation: nptName}
+		err = k8sClient.Create(ctx, canaryNs)
+		Expect(err).NotTo(HaveOccurred())
+		for _, nsName := range nsNames {
+			shouldCreateNamespace(ctx, nsName, []string{nptName})
+		}
+
+		revisions := func(g Gomega) map[string]string {
+			revs := make(map[string]string)
+			for _, nsName := range append([]string{canaryNsName}, nsNames...) {
+				cnp := cilium.CiliumNetworkPolicy()
+				err := k8sClient.Get(ctx, client.ObjectKey{Namespace: nsName, Name: nptName}, cnp)
+				g.Expect(err).NotTo(HaveOccurred())
+				revs[nsName] = cnp.GetAnnotations()[tenet.RevisionAnnotation]
+			}
+			return revs
+		}
+		oldRevision := render.Revision(intraNSTemplate)
+		newRevision := render.Revision(bmcDenyTemplate)
+		Eventually(func(g Gomega) {
+			for _, rev := range revisions(g) {
+				g.Expect(rev).To(Equal(oldRevision))
+			}
+		}).Should(Succeed())
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Spec.PolicyTemplate = bmcDenyTemplate
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("updating canary namespaces first, and waiting for the batch interval")
+		Eventually(func(g Gomega) {
+			revs := revisions(g)
+			g.Expect(revs[canaryNsName]).To(Equal(newRevision))
+			g.Expect(revs[nsNames[0]]).To(Equal(oldRevision))
+			g.Expect(revs[nsNames[1]]).To(Equal(oldRevision))
+
+			current := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.Rollout.Revision).To(Equal(newRevision))
+			g.Expect(current.Status.Rollout.PreviousRevision).To(Equal(oldRevision))
+			g.Expect(current.Status.Rollout.UpdatedNamespaces).To(Equal(1))
+			g.Expect(current.Status.Rollout.PendingNamespaces).To(Equal(2))
+		}).Should(Succeed())
+		Consistently(func(g Gomega) {
+			g.Expect(revisions(g)[nsNames[0]]).To(Equal(oldRevision))
+		}).Should(Succeed())
+
+		By("rolling back all namespaces at once")
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Annotations = map[string]string{tenet.RollbackAnnotation: "true"}
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			for _, rev := range revisions(g) {
+				g.Expect(rev).To(Equal(oldRevision))
+			}
+			current := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Spec.PolicyTemplate).To(Equal(intraNSTemplate))
+			g.Expect(current.Annotations).NotTo(HaveKey(tenet.RollbackAnnotation))
+			g.Expect(current.Status.Rollout.RollingBack).To(BeTrue())
+			g.Expect(current.Status.Rollout.PendingNamespaces).To(BeZero())
+
+			// the history keeps both revisions, most recent first.
+			g.Expect(current.Status.Revisions).To(HaveLen(2))
+			g.Expect(current.Status.Revisions[0].Revision).To(Equal(oldRevision))
+			g.Expect(current.Status.Revisions[0].PolicyTemplate).To(Equal(intraNSTemplate))
+			g.Expect(current.Status.Revisions[0].Generation).To(Equal(current.Generation))
+			g.Expect(current.Status.Revisions[1].Revision).To(Equal(newRevision))
+			g.Expect(current.Status.Revisions[1].PolicyTemplate).To(Equal(bmcDenyTemplate))
+		}).Should(Succeed())
+	})
+
+	It("should hold rollouts back while outdated canary namespaces are paused", func() {
+		nptName := uuid.NewString()
+		canaryNsName := uuid.NewString()
+		nsName := uuid.NewString()
+		npt := newDummyNetworkPolicyTemplate(client.ObjectKey{Name: nptName}, intraNSTemplate)
+		npt.Spec.Rollout = &tenetv1beta3.NetworkPolicyTemplateRolloutStrategy{
+			CanarySelector: &v1.LabelSelector{MatchLabels: map[string]string{"canary": nptName}},
+			BatchInterval:  v1.Duration{Duration: 2 * time.Second},
+		}
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		canaryNs := &corev1.Namespace{}
+		canaryNs.Name = canaryNsName
+		canaryNs.Labels = map[string]string{"canary": nptName}
+		canaryNs.Annotations = map[string]string{tenet.PolicyAnnotation: nptName}
+		err = k8sClient.Create(ctx, canaryNs)
+		Expect(err).NotTo(HaveOccurred())
+		shouldCreateNamespace(ctx, nsName, []string{nptName})
+
+		revision := func(nsName string) func(g Gomega) string {
+			return func(g Gomega) string {
+				cnp := cilium.CiliumNetworkPolicy()
+				err := k8sClient.Get(ctx, client.ObjectKey{Namespace: nsName, Name: nptName}, cnp)
+				g.Expect(err).NotTo(HaveOccurred())
+				return cnp.GetAnnotations()[tenet.RevisionAnnotation]
+			}
+		}
+		setCanaryPaused := func(paused bool) {
+			ns := &corev1.Namespace{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: canaryNsName}, ns)
+			Expect(err).NotTo(HaveOccurred())
+			if paused {
+				ns.Annotations[tenet.PausedAnnotation] = "testing"
+			} else {
+				delete(ns.Annotations, tenet.PausedAnnotation)
+			}
+			err = k8sClient.Update(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		oldRevision := render.Revision(intraNSTemplate)
+		newRevision := render.Revision(bmcDenyTemplate)
+		Eventually(revision(canaryNsName)).Should(Equal(oldRevision))
+		Eventually(revision(nsName)).Should(Equal(oldRevision))
+
+		By("pausing the canary namespace before updating the template")
+		setCanaryPaused(true)
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Spec.PolicyTemplate = bmcDenyTemplate
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(current.Status.Rollout.Revision).To(Equal(newRevision))
+			g.Expect(current.Status.Rollout.PendingNamespaces).To(Equal(1))
+		}).Should(Succeed())
+		Consistently(revision(nsName)).Should(Equal(oldRevision))
+		Expect(revision(canaryNsName)(Default)).To(Equal(oldRevision))
+
+		By("unpausing the canary namespace, then pausing it again once it is updated")
+		setCanaryPaused(false)
+		Eventually(revision(canaryNsName)).Should(Equal(newRevision))
+		setCanaryPaused(true)
+		Eventually(revision(nsName)).Should(Equal(newRevision))
+	})
+
 	It("should reconcile generated CiliumNetworkPolicies upon tenant edit", func() {
 		nptName := uuid.NewString()
 		nsName := uuid.NewString()
@@ -375,7 +573,7 @@ var _ = Describe("Tenet controller", func() {
 		shouldCreateNetworkPolicyTemplate(ctx, nptName, intraNSTemplate)
 		shouldCreateNamespace(ctx, nsName, []string{nptName})
 
-		npt := &tenetv1beta2.NetworkPolicyTemplate{}
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
 		nptKey := client.ObjectKey{
 			Name: nptName,
 		}
@@ -413,7 +611,7 @@ var _ = Describe("Tenet controller", func() {
 		shouldCreateNetworkPolicyTemplate(ctx, bmcNptName, bmcDenyTemplate)
 		shouldCreateNamespace(ctx, nsName, []string{intraNSNptName, bmcNptName})
 
-		npt := &tenetv1beta2.NetworkPolicyTemplate{}
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
 		nptKey := client.ObjectKey{
 			Name: intraNSNptName,
 		}
@@ -462,15 +660,15 @@ var _ = Describe("Tenet controller", func() {
 		shouldCreateNetworkPolicyTemplate(ctx, nptName, invalidTemplate)
 		shouldCreateNamespace(ctx, nsName, []string{nptName})
 
-		Eventually(func() tenetv1beta2.NetworkPolicyTemplateStatus {
-			npt := &tenetv1beta2.NetworkPolicyTemplate{}
+		Eventually(func() tenetv1beta3.NetworkPolicyTemplateState {
+			npt := &tenetv1beta3.NetworkPolicyTemplate{}
 			nptKey := client.ObjectKey{
 				Name: nptName,
 			}
 			err := k8sClient.Get(ctx, nptKey, npt)
 			Expect(err).NotTo(HaveOccurred())
-			return npt.Status
-		}).Should(Equal(tenetv1beta2.NetworkPolicyTemplateInvalid))
+			return npt.Status.State
+		}).Should(Equal(tenetv1beta3.NetworkPolicyTemplateInvalid))
 
 		Consistently(func() error {
 			cnp := cilium.CiliumNetworkPolicy()
@@ -481,4 +679,215 @@ var _ = Describe("Tenet controller", func() {
 			return k8sClient.Get(ctx, key, cnp)
 		}).ShouldNot(Succeed())
 	})
+
+	It("should not apply policies denied by NetworkPolicyAdmissionRules", func() {
+		nptName := uuid.NewString()
+		nsName := uuid.NewString()
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					Namespaces: []string{nsName},
+				},
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.16.0/20",
+						Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeEgress,
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+		shouldCreateNetworkPolicyTemplate(ctx, nptName, bmcEgressTemplate)
+		shouldCreateNamespace(ctx, nsName, []string{nptName})
+
+		Eventually(func(g Gomega) {
+			npt := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(meta.IsStatusConditionTrue(npt.Status.Conditions, tenetv1beta3.NetworkPolicyTemplateAdmissionDenied)).To(BeTrue())
+			g.Expect(npt.Status.AdmissionDenials).To(HaveLen(1))
+			g.Expect(npt.Status.AdmissionDenials[0].Namespace).To(Equal(nsName))
+			g.Expect(npt.Status.AdmissionDenials[0].Rules).To(Equal([]string{npar.Name}))
+		}).Should(Succeed())
+		Expect(testutil.ToFloat64(templateNamespaces.WithLabelValues(nptName, templateStateDenied))).To(BeNumerically("==", 1))
+
+		key := client.ObjectKey{Namespace: nsName, Name: nptName}
+		Consistently(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumNetworkPolicy())
+		}).ShouldNot(Succeed())
+
+		err = k8sClient.Delete(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumNetworkPolicy())
+		}).Should(Succeed())
+		Eventually(func(g Gomega) {
+			npt := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(meta.IsStatusConditionFalse(npt.Status.Conditions, tenetv1beta3.NetworkPolicyTemplateAdmissionDenied)).To(BeTrue())
+			g.Expect(npt.Status.AdmissionDenials).To(BeEmpty())
+		}).Should(Succeed())
+	})
+
+	It("should leave policies of paused namespaces and templates as they are", func() {
+		nptName := uuid.NewString()
+		nsName := uuid.NewString()
+		shouldCreateNetworkPolicyTemplate(ctx, nptName, intraNSTemplate)
+		shouldCreateNamespace(ctx, nsName, []string{nptName})
+
+		key := client.ObjectKey{Namespace: nsName, Name: nptName}
+		revision := func(g Gomega) string {
+			cnp := cilium.CiliumNetworkPolicy()
+			err := k8sClient.Get(ctx, key, cnp)
+			g.Expect(err).NotTo(HaveOccurred())
+			return cnp.GetAnnotations()[tenet.RevisionAnnotation]
+		}
+		Eventually(revision).Should(Equal(render.Revision(intraNSTemplate)))
+
+		By("pausing the namespace")
+		ns := &corev1.Namespace{}
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: nsName}, ns)
+		Expect(err).NotTo(HaveOccurred())
+		ns.Annotations[tenet.PausedAnnotation] = "incident"
+		err = k8sClient.Update(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Spec.PolicyTemplate = bmcDenyTemplate
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, current)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(meta.IsStatusConditionTrue(current.Status.Conditions, tenetv1beta3.NetworkPolicyTemplatePaused)).To(BeTrue())
+			g.Expect(current.Status.PausedNamespaces).To(Equal([]tenetv1beta3.NetworkPolicyTemplatePausedNamespace{{
+				Namespace: nsName,
+				Reason:    tenetv1beta3.NetworkPolicyTemplateNamespacePaused,
+				Message:   "incident",
+			}}))
+		}).Should(Succeed())
+		Expect(testutil.ToFloat64(templateNamespaces.WithLabelValues(nptName, templateStatePaused))).To(BeNumerically("==", 1))
+		Consistently(revision).Should(Equal(render.Revision(intraNSTemplate)))
+
+		By("unpausing the namespace")
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nsName}, ns)
+		Expect(err).NotTo(HaveOccurred())
+		delete(ns.Annotations, tenet.PausedAnnotation)
+		err = k8sClient.Update(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(revision).Should(Equal(render.Revision(bmcDenyTemplate)))
+
+		By("deleting the paused template")
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Spec.Paused = true
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Delete(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Consistently(func(g Gomega) {
+			err := k8sClient.Get(ctx, key, cilium.CiliumNetworkPolicy())
+			g.Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, &tenetv1beta3.NetworkPolicyTemplate{})
+			g.Expect(err).NotTo(HaveOccurred())
+		}).Should(Succeed())
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		npt.Spec.Paused = false
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumNetworkPolicy())
+		}).ShouldNot(Succeed())
+	})
+
+	It("should keep CiliumClusterwideNetworkPolicies of paused namespaces when finalizing", func() {
+		nptName := uuid.NewString()[:16]
+		nsName := uuid.NewString()[:16]
+
+		npt := newDummyNetworkPolicyTemplate(client.ObjectKey{Name: nptName}, intraNSCCNPTemplate)
+		npt.Spec.ClusterWide = true
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		shouldCreateNamespace(ctx, nsName, []string{nptName})
+
+		key := client.ObjectKey{Name: fmt.Sprintf("%s-%s", nsName, nptName)}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumClusterwideNetworkPolicy())
+		}).Should(Succeed())
+
+		ns := &corev1.Namespace{}
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nsName}, ns)
+		Expect(err).NotTo(HaveOccurred())
+		ns.Annotations[tenet.PausedAnnotation] = "incident"
+		err = k8sClient.Update(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Delete(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) {
+			current := &tenetv1beta3.NetworkPolicyTemplate{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, current)
+			g.Expect(err).NotTo(HaveOccurred())
+			cond := meta.FindStatusCondition(current.Status.Conditions, tenetv1beta3.NetworkPolicyTemplatePaused)
+			g.Expect(cond).NotTo(BeNil())
+			g.Expect(cond.Reason).To(Equal("FinalizationPaused"))
+			g.Expect(current.Status.PausedNamespaces).To(HaveLen(1))
+			g.Expect(current.Status.PausedNamespaces[0].Namespace).To(Equal(nsName))
+		}).Should(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumClusterwideNetworkPolicy())
+		}).Should(Succeed())
+
+		err = k8sClient.Get(ctx, client.ObjectKey{Name: nsName}, ns)
+		Expect(err).NotTo(HaveOccurred())
+		delete(ns.Annotations, tenet.PausedAnnotation)
+		err = k8sClient.Update(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, cilium.CiliumClusterwideNetworkPolicy())
+		}).ShouldNot(Succeed())
+	})
+
+	It("should export metrics of templates", func() {
+		nptName := uuid.NewString()
+		invalidNptName := uuid.NewString()
+		nsName := uuid.NewString()
+		shouldCreateNetworkPolicyTemplate(ctx, nptName, intraNSTemplate)
+		shouldCreateNetworkPolicyTemplate(ctx, invalidNptName, invalidTemplate)
+		shouldCreateNamespace(ctx, nsName, []string{nptName, invalidNptName})
+
+		Eventually(func(g Gomega) {
+			g.Expect(testutil.ToFloat64(templateNamespaces.WithLabelValues(nptName, templateStateApplied))).To(BeNumerically(">=", 1))
+			g.Expect(testutil.ToFloat64(templateNamespaces.WithLabelValues(invalidNptName, templateStateInvalid))).To(BeNumerically(">=", 1))
+			g.Expect(testutil.ToFloat64(renderErrorsTotal.WithLabelValues(invalidNptName))).To(BeNumerically(">=", 1))
+			g.Expect(testutil.ToFloat64(generatedPolicies)).To(BeNumerically(">=", 1))
+		}).Should(Succeed())
+
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
+		err := k8sClient.Get(ctx, client.ObjectKey{Name: nptName}, npt)
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Delete(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() bool {
+			return hasTemplateMetrics(nptName)
+		}).Should(BeFalse())
+		Expect(hasTemplateMetrics(invalidNptName)).To(BeTrue())
+	})
 })
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/tenet"
+)
+
+// maxPausedNamespaces is the maximum number of paused namespaces listed in the status of a template.
+const maxPausedNamespaces = 50
+
+// pauseReason returns why the reconciliation of the policy generated from the template for the namespace is paused,
+// or nil if it is not paused.
+// The namespace is nil for clusterwide policies, which are only paused by the template.
+func pauseReason(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace) *tenetv1beta3.NetworkPolicyTemplatePausedNamespace {
+	paused := &tenetv1beta3.NetworkPolicyTemplatePausedNamespace{}
+	if ns != nil {
+		paused.Namespace = ns.Name
+	}
+	if npt.Spec.Paused {
+		paused.Reason = tenetv1beta3.NetworkPolicyTemplateSpecPaused
+		return paused
+	}
+	if ns == nil {
+		return nil
+	}
+	message, ok := ns.Annotations[tenet.PausedAnnotation]
+	if !ok {
+		return nil
+	}
+	paused.Reason = tenetv1beta3.NetworkPolicyTemplateNamespacePaused
+	paused.Message = message
+	return paused
+}
+
+// setPaused sets the Paused condition from the paused namespaces found during the reconciliation.
+func setPaused(npt *tenetv1beta3.NetworkPolicyTemplate) {
+	paused := npt.Status.PausedNamespaces
+	slices.SortFunc(paused, func(a, b tenetv1beta3.NetworkPolicyTemplatePausedNamespace) int {
+		return cmp.Compare(a.Namespace, b.Namespace)
+	})
+
+	condition := v1.Condition{
+		Type:               tenetv1beta3.NetworkPolicyTemplatePaused,
+		Status:             v1.ConditionFalse,
+		Reason:             "Active",
+		Message:            "policies are reconciled in all opted-in namespaces",
+		ObservedGeneration: npt.Generation,
+	}
+	switch {
+	case npt.Spec.Paused:
+		condition.Status = v1.ConditionTrue
+		condition.Reason = tenetv1beta3.NetworkPolicyTemplateSpecPaused
+		condition.Message = "the template is paused; generated policies are left as they are"
+	case len(paused) > 0:
+		namespaces := make([]string, 0, len(paused))
+		for _, p := range paused[:min(len(paused), maxPausedNamespaces)] {
+			namespaces = append(namespaces, p.Namespace)
+		}
+		condition.Status = v1.ConditionTrue
+		condition.Reason = tenetv1beta3.NetworkPolicyTemplateNamespacePaused
+		condition.Message = fmt.Sprintf("reconciliation is paused in %d namespaces: %s", len(paused), strings.Join(namespaces, ", "))
+	}
+	meta.SetStatusCondition(&npt.Status.Conditions, condition)
+	npt.Status.PausedNamespaces = paused[:min(len(paused), maxPausedNamespaces)]
+}
+
+// pauseFinalization reports the policies kept by the finalization of the template because they are paused,
+// and records an event when they change.
+func (r *NetworkPolicyTemplateReconciler) pauseFinalization(ctx context.Context, npt *tenetv1beta3.NetworkPolicyTemplate, paused []tenetv1beta3.NetworkPolicyTemplatePausedNamespace) error {
+	slices.SortFunc(paused, func(a, b tenetv1beta3.NetworkPolicyTemplatePausedNamespace) int {
+		return cmp.Compare(a.Namespace, b.Namespace)
+	})
+	condition := v1.Condition{
+		Type:               tenetv1beta3.NetworkPolicyTemplatePaused,
+		Status:             v1.ConditionTrue,
+		Reason:             "FinalizationPaused",
+		Message:            fmt.Sprintf("%d generated policies are paused; the template is deleted once they are unpaused", len(paused)),
+		ObservedGeneration: npt.Generation,
+	}
+	if c := meta.FindStatusCondition(npt.Status.Conditions, condition.Type); c == nil || c.Reason != condition.Reason || c.Message != condition.Message {
+		r.Recorder.Eventf(npt, nil, corev1.EventTypeWarning, condition.Reason, "Finalize", condition.Message)
+	}
+	meta.SetStatusCondition(&npt.Status.Conditions, condition)
+	npt.Status.PausedNamespaces = paused[:min(len(paused), maxPausedNamespaces)]
+	return r.Status().Update(ctx, npt)
+}
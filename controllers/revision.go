@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"slices"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+// maxRevisionHistory is the maximum number of revisions kept in the status of a template.
+const maxRevisionHistory = 10
+
+// recordRevision records the current policy template at the head of the revision history of the template.
+// A revision already in the history, e.g. after a rollback, is moved to the head.
+func recordRevision(npt *tenetv1beta3.NetworkPolicyTemplate, now v1.Time) {
+	revision := render.Revision(npt.Spec.PolicyTemplate)
+	history := npt.Status.Revisions
+	if len(history) > 0 && history[0].Revision == revision {
+		return
+	}
+	history = slices.DeleteFunc(history, func(r tenetv1beta3.NetworkPolicyTemplateRevision) bool {
+		return r.Revision == revision
+	})
+	history = slices.Insert(history, 0, tenetv1beta3.NetworkPolicyTemplateRevision{
+		Revision:          revision,
+		Generation:        npt.Generation,
+		PolicyTemplate:    npt.Spec.PolicyTemplate,
+		CreationTimestamp: now,
+	})
+	npt.Status.Revisions = history[:min(len(history), maxRevisionHistory)]
+}
+
+// findRevision returns the revision from the history of the template, or nil if it is not there.
+func findRevision(npt *tenetv1beta3.NetworkPolicyTemplate, revision string) *tenetv1beta3.NetworkPolicyTemplateRevision {
+	i := slices.IndexFunc(npt.Status.Revisions, func(r tenetv1beta3.NetworkPolicyTemplateRevision) bool {
+		return r.Revision == revision
+	})
+	if i < 0 {
+		return nil
+	}
+	return &npt.Status.Revisions[i]
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+// rollout decides which namespaces get the current revision of a template during a reconciliation,
+// following the rollout strategy of the template.
+//
+// Canary namespaces are updated as soon as a new revision appears. The other namespaces are updated
+// by batches once no canary namespace is outdated and BatchInterval has elapsed since the last update.
+// A rollback to the previous revision, and templates without a strategy, update all namespaces at once.
+type rollout struct {
+	strategy *tenetv1beta3.NetworkPolicyTemplateRolloutStrategy
+	status   *tenetv1beta3.NetworkPolicyTemplateRolloutStatus
+	canary   labels.Selector
+	now      v1.Time
+
+	// budget is the number of namespaces the current batch may still update, or -1 if unlimited.
+	budget int
+	// waiting is true when canary namespaces are updated or outdated, so that batches must wait.
+	waiting bool
+	// started is true when some namespaces were allowed to update.
+	started bool
+	// held is true when an outdated canary namespace is paused, so that batches must wait until it is unpaused.
+	held bool
+}
+
+// newRollout starts a new rollout in the status of the template if its revision changed,
+// and returns the rollout for the current reconciliation.
+func newRollout(npt *tenetv1beta3.NetworkPolicyTemplate, now v1.Time) (*rollout, error) {
+	status := &npt.Status.Rollout
+	revision := render.Revision(npt.Spec.PolicyTemplate)
+	if status.Revision != revision {
+		*status = tenetv1beta3.NetworkPolicyTemplateRolloutStatus{
+			Revision:         revision,
+			PreviousRevision: status.Revision,
+			RollingBack:      status.Revision != "" && status.PreviousRevision == revision,
+		}
+	}
+
+	ro := &rollout{
+		strategy: npt.Spec.Rollout,
+		status:   status,
+		canary:   labels.Nothing(),
+		now:      now,
+		budget:   -1,
+	}
+	if ro.strategy == nil {
+		return ro, nil
+	}
+	if ro.strategy.CanarySelector != nil {
+		sel, err := v1.LabelSelectorAsSelector(ro.strategy.CanarySelector)
+		if err != nil {
+			return nil, err
+		}
+		ro.canary = sel
+	}
+	if ro.strategy.BatchSize > 0 {
+		ro.budget = ro.strategy.BatchSize
+	}
+	if last := status.LastBatchTime; last != nil && now.Time.Before(last.Add(ro.strategy.BatchInterval.Duration)) {
+		ro.budget = 0
+	}
+	return ro, nil
+}
+
+// isCanary reports whether the namespace is a canary namespace.
+func (ro *rollout) isCanary(ns *corev1.Namespace) bool {
+	return ro.canary.Matches(labels.Set(ns.Labels))
+}
+
+// admit reports whether the outdated policy of the namespace may be updated to the current revision now.
+// Canary namespaces must be passed before the others.
+func (ro *rollout) admit(ns *corev1.Namespace) bool {
+	if ro.strategy == nil || ro.status.RollingBack {
+		return true
+	}
+	if ro.isCanary(ns) {
+		ro.waiting = true
+		ro.started = true
+		return true
+	}
+	if ro.waiting || ro.budget == 0 {
+		return false
+	}
+	if ro.budget > 0 {
+		ro.budget--
+	}
+	ro.started = true
+	return true
+}
+
+// pause records that the namespace is paused with a policy of the given revision.
+// A paused canary namespace whose policy is outdated holds the batches back until it is unpaused.
+func (ro *rollout) pause(ns *corev1.Namespace, revision string) {
+	if ro.strategy == nil || ro.status.RollingBack || !ro.isCanary(ns) || revision == ro.status.Revision {
+		return
+	}
+	ro.waiting = true
+	ro.held = true
+}
+
+// finish records the progress of the rollout in the status, and returns the time to wait
+// before the next batch can be updated, or zero if the rollout does not need to be resumed.
+func (ro *rollout) finish(updated, pending int) time.Duration {
+	ro.status.UpdatedNamespaces = updated
+	ro.status.PendingNamespaces = pending
+	if ro.strategy == nil {
+		ro.status.LastBatchTime = nil
+		return 0
+	}
+	if ro.started {
+		ro.status.LastBatchTime = &ro.now
+	}
+	// a rollout held back by paused canary namespaces is resumed when they are unpaused.
+	if pending == 0 || ro.status.LastBatchTime == nil || (ro.held && !ro.started) {
+		return 0
+	}
+	wait := ro.status.LastBatchTime.Add(ro.strategy.BatchInterval.Duration).Sub(ro.now.Time)
+	return max(wait, time.Second)
+}
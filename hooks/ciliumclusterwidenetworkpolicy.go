@@ -0,0 +1,41 @@
+package hooks
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/cybozu-go/tenet/pkg/cilium"
+)
+
+//+kubebuilder:webhook:path=/validate-cilium-io-v2-ciliumclusterwidenetworkpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=cilium.io,resources=ciliumclusterwidenetworkpolicies,verbs=update;delete,versions=v2,name=vciliumclusterwidenetworkpolicy.kb.io,admissionReviewVersions={v1}
+
+type ciliumClusterwideNetworkPolicyValidator struct {
+	dec   admission.Decoder
+	guard *generatedPolicyGuard
+}
+
+var _ admission.Handler = &ciliumClusterwideNetworkPolicyValidator{}
+
+// Handle protects CiliumClusterwideNetworkPolicies generated from NetworkPolicyTemplates.
+func (v *ciliumClusterwideNetworkPolicyValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	switch req.Operation {
+	case admissionv1.Delete, admissionv1.Update:
+		if resp, denied := v.guard.handleDeleteOrUpdate(ctx, req, v.dec, cilium.CiliumClusterwideNetworkPolicy); denied {
+			return resp
+		}
+	}
+	return admission.Allowed("")
+}
+
+func SetupCiliumClusterwideNetworkPolicyWebhook(mgr manager.Manager, dec admission.Decoder, sa string, privileged PrivilegedIdentities) {
+	v := &ciliumClusterwideNetworkPolicyValidator{
+		dec:   dec,
+		guard: newGeneratedPolicyGuard(mgr, sa, privileged),
+	}
+	srv := mgr.GetWebhookServer()
+	srv.Register("/validate-cilium-io-v2-ciliumclusterwidenetworkpolicy", &webhook.Admission{Handler: instrument("ciliumclusterwidenetworkpolicy", v)})
+}
@@ -2,29 +2,33 @@ package hooks
 
 import (
 	"context"
+	"fmt"
 	"net/http"
-	"slices"
+	"strings"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/events"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/policy"
 )
 
-//+kubebuilder:webhook:path=/validate-cilium-io-v2-ciliumnetworkpolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=cilium.io,resources=ciliumnetworkpolicies,verbs=create;update;delete,versions=v2,name=vciliumnetworkpolicy.kb.io,admissionReviewVersions={v1}
+//+kubebuilder:webhook:path=/validate-cilium-io-v2-ciliumnetworkpolicy,mutating=false,failurePolicy=fail,sideEffects=NoneOnDryRun,groups=cilium.io,resources=ciliumnetworkpolicies,verbs=create;update;delete,versions=v2,name=vciliumnetworkpolicy.kb.io,admissionReviewVersions={v1}
 
 type ciliumNetworkPolicyValidator struct {
 	client.Client
-	dec                admission.Decoder
-	serviceAccountName string
+	dec       admission.Decoder
+	recorder  events.EventRecorder
+	guard     *generatedPolicyGuard
+	evaluator policy.Evaluator
 }
 
 var _ admission.Handler = &ciliumNetworkPolicyValidator{}
@@ -37,32 +41,23 @@ func (v *ciliumNetworkPolicyValidator) Handle(ctx context.Context, req admission
 	case admissionv1.Create:
 		return v.handleCreateOrUpdate(ctx, req)
 	case admissionv1.Update:
+		if resp, denied := v.guard.handleDeleteOrUpdate(ctx, req, v.dec, cilium.CiliumNetworkPolicy); denied {
+			return resp
+		}
 		return v.handleCreateOrUpdate(ctx, req)
 	default:
 		return admission.Allowed("")
 	}
 }
 
-func (v *ciliumNetworkPolicyValidator) handleDelete(_ context.Context, req admission.Request) admission.Response {
-	cnp := cilium.CiliumNetworkPolicy()
-	if err := v.dec.DecodeRaw(req.OldObject, cnp); err != nil {
-		return admission.Errored(http.StatusBadRequest, err)
-	}
-	owners := cnp.GetOwnerReferences()
-	for _, owner := range owners {
-		if owner.APIVersion == tenetv1beta2.GroupVersion.String() && owner.Kind == tenetv1beta2.NetworkPolicyTemplateKind {
-			if req.UserInfo.Username == v.serviceAccountName {
-				return admission.Allowed("deletion by service account")
-			}
-			return admission.Denied("user deletion is not allowed")
-		}
+func (v *ciliumNetworkPolicyValidator) handleDelete(ctx context.Context, req admission.Request) admission.Response {
+	if resp, denied := v.guard.handleDeleteOrUpdate(ctx, req, v.dec, cilium.CiliumNetworkPolicy); denied {
+		return resp
 	}
 	return admission.Allowed("")
 }
 
 func (v *ciliumNetworkPolicyValidator) handleCreateOrUpdate(ctx context.Context, req admission.Request) admission.Response {
-	var res admission.Response
-
 	cnp := cilium.CiliumNetworkPolicy()
 	if err := v.dec.Decode(req, cnp); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
@@ -71,85 +66,50 @@ func (v *ciliumNetworkPolicyValidator) handleCreateOrUpdate(ctx context.Context,
 	if err := v.Get(ctx, client.ObjectKey{Name: cnp.GetNamespace()}, ns); client.IgnoreNotFound(err) != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
-	var nparl tenetv1beta2.NetworkPolicyAdmissionRuleList
+	ns.Name = cnp.GetNamespace()
+	var nparl tenetv1beta3.NetworkPolicyAdmissionRuleList
 	if err := v.List(ctx, &nparl); err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
-
-	res = v.validateIP(nparl, cnp, ns.Labels)
-	if !res.Allowed {
-		return res
-	}
-
-	return v.validateEntity(nparl, cnp, ns.Labels)
-}
-
-func (v *ciliumNetworkPolicyValidator) validateIP(nparl tenetv1beta2.NetworkPolicyAdmissionRuleList, cnp *unstructured.Unstructured, ls map[string]string) admission.Response {
-	egressPolicies, ingressPolicies, err := v.gatherIPPolicies(cnp)
-	if err != nil {
-		return admission.Errored(http.StatusBadRequest, err)
-	}
-
-	egressFilters, ingressFilters, err := v.gatherIPFilters(&nparl, ls)
-	if err != nil {
+	var npael tenetv1beta2.NetworkPolicyAdmissionExceptionList
+	if err := v.List(ctx, &npael); err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
-	for _, egressPolicy := range egressPolicies {
-		for _, egressFilter := range egressFilters {
-			if v.intersectIP(egressPolicy, egressFilter) {
-				return admission.Denied("an egress policy is requesting a forbidden IP range")
-			}
-		}
-	}
-	for _, ingressPolicy := range ingressPolicies {
-		for _, ingressFilter := range ingressFilters {
-			if v.intersectIP(ingressPolicy, ingressFilter) {
-				return admission.Denied("an ingress policy is requesting a forbidden IP range")
-			}
-		}
-	}
-	return admission.Allowed("")
-}
 
-func (v *ciliumNetworkPolicyValidator) validateEntity(nparl tenetv1beta2.NetworkPolicyAdmissionRuleList, cnp *unstructured.Unstructured, ls map[string]string) admission.Response {
-	egressPolicies, ingressPolicies, err := v.gatherEntityPolicies(cnp)
+	violations, err := v.evaluator.Evaluate(nparl.Items, ns, cnp)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	egressFilters, ingressFilters, err := v.gatherEntityFilters(&nparl, ls)
-	if err != nil {
-		return admission.Errored(http.StatusBadRequest, err)
+	violations, exempted := policy.Exempt(npael.Items, cnp.GetNamespace(), violations, v1.Now())
+
+	e := &enforcer{
+		recorder: v.recorder,
+		cnp:      cnp,
+		dryRun:   req.DryRun != nil && *req.DryRun,
 	}
-	for _, egressPolicy := range egressPolicies {
-		if slices.Contains(egressFilters, egressPolicy) {
-			return admission.Denied("an egress policy is requesting a forbidden entity")
-		}
+	for _, ev := range exempted {
+		recordAdmissionDecision(ev.Violation, admissionResultExempted, e.dryRun)
+		e.warnings = append(e.warnings, ev.String())
 	}
-	for _, ingressPolicy := range ingressPolicies {
-		if slices.Contains(ingressFilters, ingressPolicy) {
-			return admission.Denied("an ingress policy is requesting a forbidden entity")
+	var denials []string
+	for _, violation := range violations {
+		if !e.enforce(violation) {
+			denials = append(denials, violation.String())
 		}
 	}
-	return admission.Allowed("")
-}
-
-func (v *ciliumNetworkPolicyValidator) shouldExclude(npar *tenetv1beta2.NetworkPolicyAdmissionRule, ls map[string]string) (bool, error) {
-	s, err := v1.LabelSelectorAsSelector(&v1.LabelSelector{
-		MatchLabels:      npar.Spec.NamespaceSelector.ExcludeLabels,
-		MatchExpressions: npar.Spec.NamespaceSelector.ExcludeLabelExpressions,
-	})
-	if err != nil {
-		return false, err
+	if len(denials) > 0 {
+		return admission.Denied(fmt.Sprintf("the policy violates NetworkPolicyAdmissionRules: %s", strings.Join(denials, "; "))).WithWarnings(e.warnings...)
 	}
-	return s.Matches(labels.Set(ls)), nil
+	return admission.Allowed("").WithWarnings(e.warnings...)
 }
 
-func SetupCiliumNetworkPolicyWebhook(mgr manager.Manager, dec admission.Decoder, sa string) {
+func SetupCiliumNetworkPolicyWebhook(mgr manager.Manager, dec admission.Decoder, sa string, privileged PrivilegedIdentities) {
 	v := &ciliumNetworkPolicyValidator{
-		Client:             mgr.GetClient(),
-		dec:                dec,
-		serviceAccountName: sa,
+		Client:   mgr.GetClient(),
+		dec:      dec,
+		recorder: mgr.GetEventRecorder("tenet-webhook"),
+		guard:    newGeneratedPolicyGuard(mgr, sa, privileged),
 	}
 	srv := mgr.GetWebhookServer()
-	srv.Register("/validate-cilium-io-v2-ciliumnetworkpolicy", &webhook.Admission{Handler: v})
+	srv.Register("/validate-cilium-io-v2-ciliumnetworkpolicy", &webhook.Admission{Handler: instrument("ciliumnetworkpolicy", v)})
 }
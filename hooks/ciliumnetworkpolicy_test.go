@@ -5,29 +5,58 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	"github.com/cybozu-go/tenet/pkg/cilium"
 )
 
 var (
 	//go:embed t/allowed-cidr.yaml
 	allowedCIDR []byte
+	//go:embed t/allowed-entity.yaml
+	allowedEntity []byte
 	//go:embed t/egress-forbidden-cidrset.yaml
 	egressForbiddenCIDRSet []byte
+	//go:embed t/egress-allowed-union-cidr.yaml
+	egressAllowedUnionCIDR []byte
+	//go:embed t/egress-outside-allowed-cidr.yaml
+	egressOutsideAllowedCIDR []byte
+	//go:embed t/egress-deny-forbidden-cidr.yaml
+	egressDenyForbiddenCIDR []byte
 	//go:embed t/egress-forbidden-cidr.yaml
 	egressForbiddenCIDR []byte
+	//go:embed t/egress-required-deny-missing.yaml
+	egressRequiredDenyMissing []byte
+	//go:embed t/egress-required-deny-other-rule.yaml
+	egressRequiredDenyOtherRule []byte
+	//go:embed t/egress-required-deny-covered.yaml
+	egressRequiredDenyCovered []byte
+	//go:embed t/egress-forbidden-mapped-cidr.yaml
+	egressForbiddenMappedCIDR []byte
+	//go:embed t/egress-dual-stack-cidr.yaml
+	egressDualStackCIDR []byte
+	//go:embed t/egress-dual-stack-any-ipv6.yaml
+	egressDualStackAnyIPv6 []byte
 	//go:embed t/egress-forbidden-entity.yaml
 	egressForbiddenEntity []byte
+	//go:embed t/egress-forbidden-entity-child.yaml
+	egressForbiddenEntityChild []byte
+	//go:embed t/egress-forbidden-entity-parent.yaml
+	egressForbiddenEntityParent []byte
 	//go:embed t/ingress-forbidden-cidrset.yaml
 	ingressForbiddenCIDRSet []byte
 	//go:embed t/ingress-forbidden-cidr.yaml
@@ -41,29 +70,46 @@ var (
 )
 
 func createCiliumNetworkPolicy(ctx context.Context, nsName string, contents []byte) error {
+	return createCiliumNetworkPolicyWith(ctx, k8sClient, nsName, contents)
+}
+
+func createCiliumNetworkPolicyWith(ctx context.Context, c client.Client, nsName string, contents []byte) error {
 	y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(contents), len(contents))
 	cnp := cilium.CiliumNetworkPolicy()
 	err := y.Decode(cnp)
 	Expect(err).NotTo(HaveOccurred())
 	cnp.SetNamespace(nsName)
-	return k8sClient.Create(ctx, cnp)
+	return c.Create(ctx, cnp)
+}
+
+// shouldCreateOwnerTemplate creates a NetworkPolicyTemplate and returns an owner reference to it.
+func shouldCreateOwnerTemplate(ctx context.Context) v1.OwnerReference {
+	npt := newNetworkPolicyTemplate(false, validPolicyTemplate)
+	err := k8sClient.Create(ctx, npt)
+	Expect(err).NotTo(HaveOccurred())
+	return v1.OwnerReference{
+		APIVersion: tenetv1beta3.GroupVersion.String(),
+		Kind:       tenetv1beta3.NetworkPolicyTemplateKind,
+		Name:       npt.Name,
+		UID:        npt.UID,
+	}
 }
 
 var _ = Describe("CiliumNetworkPolicy webhook", func() {
 	ctx := context.Background()
 
 	BeforeEach(func() {
-		npar := &tenetv1beta2.NetworkPolicyAdmissionRule{
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
 			ObjectMeta: v1.ObjectMeta{
 				Name: "default-rule",
 			},
-			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
-				NamespaceSelector: tenetv1beta2.NetworkPolicyAdmissionRuleNamespaceSelector{
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
 					ExcludeLabels: map[string]string{
 						"team": "neco",
 					},
 				},
-				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
 					{
 						CIDR: "10.72.16.0/20",
 						Type: "egress",
@@ -77,7 +123,7 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 						Type: "all",
 					},
 				},
-				ForbiddenEntities: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenEntity{
+				ForbiddenEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
 					{
 						Entity: "host",
 						Type:   "egress",
@@ -96,7 +142,7 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		err := k8sClient.Create(ctx, npar)
 		Expect(err).NotTo(HaveOccurred())
 		Eventually(func() error {
-			npar := &tenetv1beta2.NetworkPolicyAdmissionRule{}
+			npar := &tenetv1beta3.NetworkPolicyAdmissionRule{}
 			key := client.ObjectKey{
 				Name: "default-rule",
 			}
@@ -105,7 +151,7 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 	})
 
 	AfterEach(func() {
-		err := k8sClient.DeleteAllOf(ctx, &tenetv1beta2.NetworkPolicyAdmissionRule{})
+		err := k8sClient.DeleteAllOf(ctx, &tenetv1beta3.NetworkPolicyAdmissionRule{})
 		Expect(err).NotTo(HaveOccurred())
 	})
 
@@ -123,6 +169,98 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("should only apply rules to namespaces matching the inclusion criteria", func() {
+		excludedName := uuid.NewString()
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "include-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					MatchLabels: map[string]string{
+						"team": "payments",
+					},
+					ExcludeNamespaces: []string{excludedName},
+				},
+				ProtectedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleProtectedIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a policy in a namespace not matching the selector")
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDenyForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a policy in an excluded namespace matching the selector")
+		ns = &corev1.Namespace{}
+		ns.Name = excludedName
+		ns.SetLabels(map[string]string{
+			"team": "payments",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, excludedName, egressDenyForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a policy in a namespace matching the selector")
+		nsName = uuid.NewString()
+		ns = &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "payments",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDenyForbiddenCIDR)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should only apply rules to the listed namespaces", func() {
+		nsName := uuid.NewString()
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "namespaces-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					Namespaces: []string{nsName},
+				},
+				ProtectedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleProtectedIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		otherName := uuid.NewString()
+		for _, name := range []string{nsName, otherName} {
+			ns := &corev1.Namespace{}
+			ns.Name = name
+			err = k8sClient.Create(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		err = createCiliumNetworkPolicy(ctx, otherName, egressDenyForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDenyForbiddenCIDR)
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("should not reject CiliumNetworkPolicies without forbidden definitions", func() {
 		nsName := uuid.NewString()
 		ns := &corev1.Namespace{}
@@ -134,6 +272,58 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("should not reject CiliumNetworkPolicies with entities unrelated to forbidden entities", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, allowedEntity)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should only reject the forbidden entity itself with exact match", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "exact-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "admin",
+					},
+				},
+				ForbiddenEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
+					{
+						Entity: "world-ipv4",
+						Type:   "egress",
+						Match:  tenetv1beta3.NetworkPolicyAdmissionRuleEntityMatchExact,
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "neco",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying an entity containing the forbidden entity")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressForbiddenEntityParent)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying the forbidden entity itself")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressForbiddenEntityChild)
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("should reject CiliumNetworkPolicies with forbidden egress definition", func() {
 		nsName := uuid.NewString()
 		ns := &corev1.Namespace{}
@@ -160,6 +350,14 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 				name:     "egress with forbidden entity",
 				manifest: egressForbiddenEntity,
 			},
+			{
+				name:     "egress with entity contained in forbidden entity",
+				manifest: egressForbiddenEntityChild,
+			},
+			{
+				name:     "egress with entity containing forbidden entity",
+				manifest: egressForbiddenEntityParent,
+			},
 		}
 		for _, tc := range cases {
 			By(fmt.Sprintf("applying %s", tc.name))
@@ -208,6 +406,352 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should not apply forbidden definitions to deny sections", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDenyForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject deny sections denying protected IP ranges", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "protect-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "admin",
+					},
+				},
+				ProtectedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleProtectedIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDenyForbiddenCIDR)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject CIDRs allowing required-deny IP ranges without denying them", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "required-deny-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "admin",
+					},
+				},
+				RequiredDenyIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleRequiredDenyIPRanges{
+					{
+						CIDR: "10.96.0.0/12",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "neco",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a CIDR overlapping the range without a deny section")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressRequiredDenyMissing)
+		Expect(err).To(MatchError(ContainSubstring("10.0.0.0/8 at spec.egress[0].toCIDR[0] overlaps 10.96.0.0/12, which must be denied by egressDeny in the same rule")))
+
+		By("applying a CIDR overlapping the range with a deny section in another rule")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressRequiredDenyOtherRule)
+		Expect(err).To(HaveOccurred())
+
+		By("applying a CIDR overlapping the range with a deny section covering it in the same rule")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressRequiredDenyCovered)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should not reject CiliumNetworkPolicies violating rules in warn or dryrun mode", func() {
+		for _, action := range []tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementAction{
+			tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionWarn,
+			tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionDryRun,
+		} {
+			By(fmt.Sprintf("applying a rule in %s mode", action))
+			npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+				ObjectMeta: v1.ObjectMeta{
+					Name: fmt.Sprintf("%s-rule", action),
+				},
+				Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+					NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+						ExcludeLabels: map[string]string{
+							"team": "admin",
+						},
+					},
+					ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+						{
+							CIDR: "10.72.0.0/16",
+							Type: "egress",
+						},
+					},
+					EnforcementAction: action,
+				},
+			}
+			err := k8sClient.Create(ctx, npar)
+			Expect(err).NotTo(HaveOccurred())
+
+			nsName := uuid.NewString()
+			ns := &corev1.Namespace{}
+			ns.Name = nsName
+			ns.SetLabels(map[string]string{
+				"team": "neco",
+			})
+			err = k8sClient.Create(ctx, ns)
+			Expect(err).NotTo(HaveOccurred())
+
+			warnings.take()
+			err = createCiliumNetworkPolicyWith(ctx, warningClient, nsName, egressForbiddenCIDR)
+			Expect(err).NotTo(HaveOccurred())
+
+			message := fmt.Sprintf("NetworkPolicyAdmissionRule %s: egress IP range 10.72.16.0/20 at spec.egress[0].toCIDR[0] overlaps forbidden 10.72.0.0/16", npar.Name)
+			if action == tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionWarn {
+				Expect(warnings.take()).To(ContainElement(message))
+				continue
+			}
+
+			Expect(warnings.take()).To(BeEmpty())
+			Eventually(func(g Gomega) {
+				events := &eventsv1.EventList{}
+				err := k8sClient.List(ctx, events)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(events.Items).To(ContainElement(And(
+					HaveField("Reason", "DryRunViolation"),
+					HaveField("Regarding.Name", npar.Name),
+					HaveField("Note", ContainSubstring("CiliumNetworkPolicy "+nsName+"/egress-with-forbidden-cidr: "+message)),
+				)))
+			}).Should(Succeed())
+		}
+	})
+
+	It("should reject CIDRs outside allowed IP ranges", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "allow-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "admin",
+					},
+				},
+				AllowedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleAllowedIPRanges{
+					{
+						CIDR: "10.0.0.0/9",
+						Type: "egress",
+					},
+					{
+						CIDR: "10.128.0.0/9",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "neco",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a CIDR covered by the union of allowed IP ranges")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressAllowedUnionCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying a CIDR outside allowed IP ranges")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressOutsideAllowedCIDR)
+		Expect(err).To(MatchError(And(
+			ContainSubstring("192.168.0.0/24 at spec.egress[0].toCIDRSet[1] is outside allowed 10.0.0.0/9, 10.128.0.0/9"),
+			Not(ContainSubstring("toCIDRSet[0]")),
+		)))
+	})
+
+	It("should reject IPv4-mapped IPv6 CIDRs overlapping forbidden IPv4 ranges", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, egressForbiddenMappedCIDR)
+		Expect(err).To(MatchError(ContainSubstring("::ffff:10.72.16.0/120 at spec.egress[0].toCIDR[0] overlaps forbidden 10.72.16.0/20")))
+	})
+
+	It("should not reject IPv6 CIDRs because of forbidden IPv4 ranges", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDualStackAnyIPv6)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should evaluate dual-stack CiliumNetworkPolicies against ranges of both families", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "dual-stack-rule",
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					ExcludeLabels: map[string]string{
+						"team": "admin",
+					},
+				},
+				AllowedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleAllowedIPRanges{
+					{
+						CIDR: "10.0.0.0/8",
+						Type: "egress",
+					},
+					{
+						CIDR: "fd00::/8",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "neco",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("applying CIDRs of both families inside allowed IP ranges")
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDualStackCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("forbidding an IPv6 range")
+		npar.Spec.ForbiddenIPRanges = []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			{
+				CIDR: "fd00:1::/32",
+				Type: "egress",
+			},
+		}
+		err = k8sClient.Update(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		nsName = uuid.NewString()
+		ns = &corev1.Namespace{}
+		ns.Name = nsName
+		ns.SetLabels(map[string]string{
+			"team": "neco",
+		})
+		err = k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressDualStackCIDR)
+		Expect(err).To(MatchError(ContainSubstring("fd00:1::/64 at spec.egress[0].toCIDR[1] overlaps forbidden fd00:1::/32")))
+	})
+
+	It("should allow forbidden definitions covered by active exceptions", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		otherNsName := uuid.NewString()
+		otherNs := &corev1.Namespace{}
+		otherNs.Name = otherNsName
+		err = k8sClient.Create(ctx, otherNs)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating an expired exception")
+		expired := &tenetv1beta2.NetworkPolicyAdmissionException{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: nsName,
+				Rule:      "default-rule",
+				CIDR:      "10.72.0.0/16",
+				ExpiresAt: v1.NewTime(time.Now().Add(-time.Hour)),
+				Reason:    "test",
+			},
+		}
+		Expect(k8sClient.Create(ctx, expired)).To(Succeed())
+		Expect(createCiliumNetworkPolicy(ctx, nsName, egressForbiddenCIDR)).To(HaveOccurred())
+
+		By("creating an active exception")
+		npae := &tenetv1beta2.NetworkPolicyAdmissionException{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: nsName,
+				Rule:      "default-rule",
+				CIDR:      "10.72.0.0/16",
+				ExpiresAt: v1.NewTime(time.Now().Add(time.Hour)),
+				Reason:    "test",
+			},
+		}
+		Expect(k8sClient.Create(ctx, npae)).To(Succeed())
+		Eventually(func() error {
+			return createCiliumNetworkPolicy(ctx, nsName, egressForbiddenCIDR)
+		}).Should(Succeed())
+
+		By("checking the exception does not apply to other namespaces, rules, or peers")
+		Expect(createCiliumNetworkPolicy(ctx, otherNsName, egressForbiddenCIDR)).To(HaveOccurred())
+		Expect(createCiliumNetworkPolicy(ctx, nsName, egressForbiddenEntity)).To(HaveOccurred())
+
+		Expect(k8sClient.DeleteAllOf(ctx, &tenetv1beta2.NetworkPolicyAdmissionException{})).To(Succeed())
+	})
+
+	It("should export metrics of admission decisions", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		denied := admissionDecisionsTotal.WithLabelValues("default-rule", "egress", admissionResultDenied)
+		before := testutil.ToFloat64(denied)
+		Expect(createCiliumNetworkPolicy(ctx, nsName, egressForbiddenCIDR)).To(HaveOccurred())
+		Expect(testutil.ToFloat64(denied)).To(Equal(before + 1))
+
+		Expect(testutil.CollectAndCount(webhookDuration, "tenet_webhook_duration_seconds")).To(BeNumerically(">", 0))
+	})
+
 	It("should handle CiliumNetworkPolicies with multiple specs", func() {
 		nsName := uuid.NewString()
 		ns := &corev1.Namespace{}
@@ -219,6 +763,21 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should report every violation in the denial message", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = createCiliumNetworkPolicy(ctx, nsName, eitherForbidden)
+		Expect(err).To(MatchError(And(
+			ContainSubstring("NetworkPolicyAdmissionRule default-rule: ingress IP range 10.76.16.0/20 at spec.ingress[0].fromCIDRSet[0] overlaps forbidden 10.76.16.0/20"),
+			ContainSubstring("NetworkPolicyAdmissionRule default-rule: ingress IP range 10.78.16.0/20 at spec.ingress[0].fromCIDRSet[1] overlaps forbidden 10.78.16.0/20"),
+			Not(ContainSubstring("10.96.16.0/20")),
+		)))
+	})
+
 	It("should block user deletion of managed CiliumNetworkPolicies", func() {
 		nsName := uuid.NewString()
 		ns := &corev1.Namespace{}
@@ -231,14 +790,8 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		err = y.Decode(cnp)
 		Expect(err).NotTo(HaveOccurred())
 		cnp.SetNamespace(nsName)
-		cnp.SetOwnerReferences([]v1.OwnerReference{
-			{
-				APIVersion: tenetv1beta2.GroupVersion.String(),
-				Kind:       "NetworkPolicyTemplate",
-				Name:       "dummy",
-				UID:        types.UID(uuid.NewString()),
-			},
-		})
+		owner := shouldCreateOwnerTemplate(ctx)
+		cnp.SetOwnerReferences([]v1.OwnerReference{owner})
 		err = k8sClient.Create(ctx, cnp)
 		Expect(err).NotTo(HaveOccurred())
 
@@ -255,6 +808,155 @@ var _ = Describe("CiliumNetworkPolicy webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should block user deletion of CiliumNetworkPolicies generated from a v1beta2 template", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(allowedCIDR), len(allowedCIDR))
+		cnp := cilium.CiliumNetworkPolicy()
+		err = y.Decode(cnp)
+		Expect(err).NotTo(HaveOccurred())
+		cnp.SetNamespace(nsName)
+		owner := shouldCreateOwnerTemplate(ctx)
+		owner.APIVersion = tenetv1beta2.GroupVersion.String()
+		cnp.SetOwnerReferences([]v1.OwnerReference{owner})
+		err = k8sClient.Create(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Delete(ctx, cnp)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should block user updates of managed CiliumNetworkPolicies", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(allowedCIDR), len(allowedCIDR))
+		cnp := cilium.CiliumNetworkPolicy()
+		err = y.Decode(cnp)
+		Expect(err).NotTo(HaveOccurred())
+		cnp.SetNamespace(nsName)
+		owner := shouldCreateOwnerTemplate(ctx)
+		cnp.SetOwnerReferences([]v1.OwnerReference{owner})
+		err = k8sClient.Create(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("updating the policy")
+		cnp.SetLabels(map[string]string{"foo": "bar"})
+		err = k8sClient.Update(ctx, cnp)
+		Expect(err).To(MatchError(ContainSubstring("user modification is not allowed")))
+
+		By("removing the owner reference of the policy")
+		cnp.SetLabels(nil)
+		cnp.SetOwnerReferences(nil)
+		err = k8sClient.Update(ctx, cnp)
+		Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("removing the owner reference to NetworkPolicyTemplate %s is not allowed", owner.Name))))
+	})
+
+	It("should allow privileged identities to update managed CiliumNetworkPolicies", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(allowedCIDR), len(allowedCIDR))
+		cnp := cilium.CiliumNetworkPolicy()
+		err = y.Decode(cnp)
+		Expect(err).NotTo(HaveOccurred())
+		cnp.SetNamespace(nsName)
+		owner := shouldCreateOwnerTemplate(ctx)
+		cnp.SetOwnerReferences([]v1.OwnerReference{owner})
+		err = k8sClient.Create(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("updating the policy as an unprivileged user")
+		cnp.SetLabels(map[string]string{"foo": "bar"})
+		err = impersonatingClient("alice").Update(ctx, cnp)
+		Expect(err).To(HaveOccurred())
+
+		By("updating the policy as a member of a privileged group")
+		groupChanges := privilegedChangesTotal.WithLabelValues(privilegeGroup, "UPDATE", "CiliumNetworkPolicy")
+		before := testutil.ToFloat64(groupChanges)
+		err = impersonatingClient("bob", "tenet:break-glass").Update(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(groupChanges)).To(Equal(before + 1))
+
+		By("updating the policy as a user listed in the ConfigMap")
+		cm := &corev1.ConfigMap{}
+		cm.Namespace = "default"
+		cm.Name = "tenet-privileged-identities"
+		cm.Data = map[string]string{
+			PrivilegedUsersKey: "alice",
+		}
+		err = k8sClient.Create(ctx, cm)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(ctx, cm)).To(Succeed())
+		})
+		configMapChanges := privilegedChangesTotal.WithLabelValues(privilegeConfigMap, "UPDATE", "CiliumNetworkPolicy")
+		before = testutil.ToFloat64(configMapChanges)
+		cnp.SetLabels(map[string]string{"foo": "baz"})
+		err = impersonatingClient("alice").Update(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(testutil.ToFloat64(configMapChanges)).To(Equal(before + 1))
+	})
+
+	It("should block user deletion and updates of managed CiliumClusterwideNetworkPolicies", func() {
+		ccnp := cilium.CiliumClusterwideNetworkPolicy()
+		ccnp.SetName(uuid.NewString())
+		owner := shouldCreateOwnerTemplate(ctx)
+		ccnp.SetOwnerReferences([]v1.OwnerReference{owner})
+		err := unstructured.SetNestedMap(ccnp.Object, map[string]any{}, "spec", "endpointSelector")
+		Expect(err).NotTo(HaveOccurred())
+		err = k8sClient.Create(ctx, ccnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		ccnp.SetLabels(map[string]string{"foo": "bar"})
+		err = k8sClient.Update(ctx, ccnp)
+		Expect(err).To(HaveOccurred())
+
+		err = k8sClient.Delete(ctx, ccnp)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should allow user updates and deletion of CiliumNetworkPolicies whose template does not exist", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(allowedCIDR), len(allowedCIDR))
+		cnp := cilium.CiliumNetworkPolicy()
+		err = y.Decode(cnp)
+		Expect(err).NotTo(HaveOccurred())
+		cnp.SetNamespace(nsName)
+		cnp.SetOwnerReferences([]v1.OwnerReference{
+			{
+				APIVersion: tenetv1beta2.GroupVersion.String(),
+				Kind:       tenetv1beta3.NetworkPolicyTemplateKind,
+				Name:       uuid.NewString(),
+				UID:        types.UID(uuid.NewString()),
+			},
+		})
+		err = k8sClient.Create(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		cnp.SetLabels(map[string]string{"foo": "bar"})
+		err = k8sClient.Update(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = k8sClient.Delete(ctx, cnp)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
 	It("should allow user deletion of unmanaged CiliumNetworkPolicies", func() {
 		nsName := uuid.NewString()
 		ns := &corev1.Namespace{}
@@ -0,0 +1,46 @@
+package hooks
+
+import (
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/events"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/policy"
+)
+
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
+
+// enforcer applies the enforcement action of NetworkPolicyAdmissionRules to violations found in a policy.
+type enforcer struct {
+	recorder events.EventRecorder
+	cnp      *unstructured.Unstructured
+	dryRun   bool
+	warnings []string
+}
+
+// enforce handles a violation and reports whether the policy may still be admitted.
+func (e *enforcer) enforce(violation policy.Violation) bool {
+	npar := violation.Rule
+	switch npar.Spec.EnforcementAction {
+	case tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionWarn:
+		recordAdmissionDecision(violation, admissionResultWarned, e.dryRun)
+		warning := violation.String()
+		if !slices.Contains(e.warnings, warning) {
+			e.warnings = append(e.warnings, warning)
+		}
+		return true
+	case tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionDryRun:
+		recordAdmissionDecision(violation, admissionResultDryRun, e.dryRun)
+		if !e.dryRun {
+			e.recorder.Eventf(npar, nil, corev1.EventTypeWarning, "DryRunViolation", "Admit",
+				"%s %s/%s: %s", e.cnp.GetKind(), e.cnp.GetNamespace(), e.cnp.GetName(), violation)
+		}
+		return true
+	default:
+		recordAdmissionDecision(violation, admissionResultDenied, e.dryRun)
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package hooks
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/cybozu-go/tenet/pkg/policy"
+)
+
+const metricsNamespace = "tenet"
+
+// Results of the evaluation of a NetworkPolicyAdmissionRule violation.
+const (
+	admissionResultDenied   = "denied"
+	admissionResultWarned   = "warned"
+	admissionResultDryRun   = "dryrun"
+	admissionResultExempted = "exempted"
+)
+
+var (
+	privilegedChangesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "privileged_changes_total",
+		Help:      "The number of changes to generated network policies allowed because they were made by a privileged identity.",
+	}, []string{"privilege", "operation", "kind"})
+
+	admissionDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_decisions_total",
+		Help:      "The number of NetworkPolicyAdmissionRule violations found in admitted network policies by result.",
+	}, []string{"rule", "direction", "result"})
+
+	webhookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_duration_seconds",
+		Help:      "The time taken by admission webhook handlers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler", "operation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(privilegedChangesTotal, admissionDecisionsTotal, webhookDuration)
+}
+
+// recordAdmissionDecision counts the result of a violation unless the request is a dry run.
+func recordAdmissionDecision(violation policy.Violation, result string, dryRun bool) {
+	if dryRun {
+		return
+	}
+	direction := "egress"
+	if strings.HasPrefix(violation.Section, "ingress") {
+		direction = "ingress"
+	}
+	admissionDecisionsTotal.WithLabelValues(violation.Rule.Name, direction, result).Inc()
+}
+
+// instrument wraps a handler to observe its latency under the given name.
+func instrument(name string, h admission.Handler) admission.Handler {
+	return admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+		start := time.Now()
+		defer func() {
+			webhookDuration.WithLabelValues(name, string(req.Operation)).Observe(time.Since(start).Seconds())
+		}()
+		return h.Handle(ctx, req)
+	})
+}
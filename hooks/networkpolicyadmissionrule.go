@@ -1,19 +1,36 @@
 package hooks
 
 import (
+	"cmp"
 	"context"
-	"net"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/netip"
+	"slices"
+	"time"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/iptrie"
+	"github.com/cybozu-go/tenet/pkg/policy"
 )
 
-//+kubebuilder:webhook:path=/validate-tenet-cybozu-io-v1beta2-networkpolicyadmissionrule,mutating=false,failurePolicy=fail,sideEffects=None,groups=tenet.cybozu.io,resources=networkpolicyadmissionrules,verbs=create;update,versions=v1beta2,name=vnetworkpolicyadmissionrule.kb.io,admissionReviewVersions={v1}
+//+kubebuilder:webhook:path=/validate-tenet-cybozu-io-v1beta3-networkpolicyadmissionrule,mutating=false,failurePolicy=fail,sideEffects=None,groups=tenet.cybozu.io,resources=networkpolicyadmissionrules,verbs=create;update,versions=v1beta3,name=vnetworkpolicyadmissionrule.kb.io,admissionReviewVersions={v1}
+
+// maxImpactWarnings is the maximum number of non-compliant network policies listed in admission warnings.
+const maxImpactWarnings = 20
+
+// impactPreviewTimeout bounds the time spent evaluating existing network policies when a rule changes,
+// well within the timeout of the webhook.
+const impactPreviewTimeout = 3 * time.Second
 
 type networkPolicyAdmissionRuleValidator struct {
 	client.Client
@@ -23,20 +40,188 @@ type networkPolicyAdmissionRuleValidator struct {
 var _ admission.Handler = &networkPolicyAdmissionRuleValidator{}
 
 // Handle validates the NetworkPolicyAdmissionRule.
+// Updates leaving the spec unchanged, such as adding or removing finalizers, are always allowed
+// so that rules accepted before the current validation can still be managed and deleted.
 func (v *networkPolicyAdmissionRuleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
-	npar := &tenetv1beta2.NetworkPolicyAdmissionRule{}
+	npar := &tenetv1beta3.NetworkPolicyAdmissionRule{}
 	if err := v.dec.Decode(req, npar); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+
+	var old *tenetv1beta3.NetworkPolicyAdmissionRule
+	if req.Operation == admissionv1.Update {
+		old = &tenetv1beta3.NetworkPolicyAdmissionRule{}
+		if err := v.dec.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, npar.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	warnings, err := v.validate(npar)
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	impact, err := v.previewImpact(ctx, old, npar)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.Allowed("").WithWarnings(append(warnings, impact...)...)
+}
+
+// previewImpact returns warnings listing the existing network policies that become non-compliant
+// when the rule changes from old to npar. old is nil when the rule is created.
+// Policies are sorted by namespace and name, and at most maxImpactWarnings of them are listed.
+// Existing network policies are evaluated against both versions of the rule in a single pass,
+// which is abandoned with a warning if it takes longer than impactPreviewTimeout.
+func (v *networkPolicyAdmissionRuleValidator) previewImpact(ctx context.Context, old, npar *tenetv1beta3.NetworkPolicyAdmissionRule) ([]string, error) {
+	rules := []tenetv1beta3.NetworkPolicyAdmissionRule{*npar}
+	if old != nil {
+		rules = append(rules, *old)
+	}
+	ctx, cancel := context.WithTimeout(ctx, impactPreviewTimeout)
+	defer cancel()
+	found, err := policy.FindViolations(ctx, v, rules)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return []string{fmt.Sprintf("existing network policies were not checked against the rule within %s", impactPreviewTimeout)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the policies violating npar but not old, along with the violations of npar.
+	var pvs []policy.PolicyViolations
+	for _, pv := range found {
+		if old != nil && slices.ContainsFunc(pv.Violations, func(violation policy.Violation) bool { return violation.Rule == &rules[1] }) {
+			continue
+		}
+		pv.Violations = slices.DeleteFunc(pv.Violations, func(violation policy.Violation) bool { return violation.Rule != &rules[0] })
+		if len(pv.Violations) > 0 {
+			pvs = append(pvs, pv)
+		}
+	}
+	if len(pvs) == 0 {
+		return nil, nil
+	}
+
+	slices.SortFunc(pvs, func(a, b policy.PolicyViolations) int {
+		return cmp.Or(
+			cmp.Compare(a.Policy.GetNamespace(), b.Policy.GetNamespace()),
+			cmp.Compare(a.Policy.GetName(), b.Policy.GetName()),
+			cmp.Compare(a.Policy.GetKind(), b.Policy.GetKind()),
+		)
+	})
+	warnings := []string{fmt.Sprintf("%s would violate the rule", pluralize(len(pvs), "existing network policy", "existing network policies"))}
+	for _, pv := range pvs[:min(len(pvs), maxImpactWarnings)] {
+		np := pv.Policy
+		name := np.GetName()
+		if np.GetNamespace() != "" {
+			name = np.GetNamespace() + "/" + name
+		}
+		msg := fmt.Sprintf("%s %s: %s", np.GetKind(), name, pv.Violations[0])
+		if len(pv.Violations) > 1 {
+			msg += fmt.Sprintf(" (and %d more)", len(pv.Violations)-1)
+		}
+		warnings = append(warnings, msg)
+	}
+	if len(pvs) > maxImpactWarnings {
+		n := len(pvs) - maxImpactWarnings
+		warnings = append(warnings, fmt.Sprintf("%s not listed", pluralize(n, "more network policy is", "more network policies are")))
+	}
+	return warnings, nil
+}
+
+// pluralize returns n followed by the singular or plural form of a noun.
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// validate checks the spec of the rule and returns warnings about suspicious but acceptable values.
+func (v *networkPolicyAdmissionRuleValidator) validate(npar *tenetv1beta3.NetworkPolicyAdmissionRule) ([]string, error) {
+	var warnings []string
+
+	validateIPRange := func(cidr string, t tenetv1beta3.NetworkPolicyAdmissionRuleType, seen map[string]bool) error {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return errors.New("a malformed CIDR string was provided")
+		}
+		if t == "" {
+			return errors.New("a connection type must be provided")
+		}
+		canonical := iptrie.Canonical(prefix)
+		if prefix != canonical {
+			warnings = append(warnings, fmt.Sprintf("CIDR %s is treated as %s", cidr, canonical))
+		}
+		key := fmt.Sprintf("%s (%s)", canonical, t)
+		if seen[key] {
+			return fmt.Errorf("a duplicate IP range was provided: %s", key)
+		}
+		seen[key] = true
+		return nil
+	}
+
+	validateEntity := func(entity string, t tenetv1beta3.NetworkPolicyAdmissionRuleType, seen map[string]bool) error {
+		if !cilium.IsEntity(entity) {
+			return fmt.Errorf("an unknown entity was provided: %s", entity)
+		}
+		if t == "" {
+			return errors.New("a connection type must be provided")
+		}
+		key := fmt.Sprintf("%s (%s)", entity, t)
+		if seen[key] {
+			return fmt.Errorf("a duplicate entity was provided: %s", key)
+		}
+		seen[key] = true
+		return nil
+	}
+
+	seen := map[string]bool{}
 	for _, ipRange := range npar.Spec.ForbiddenIPRanges {
-		if _, _, err := net.ParseCIDR(ipRange.CIDR); err != nil {
-			return admission.Denied("a malformed CIDR string was provided")
+		if err := validateIPRange(ipRange.CIDR, ipRange.Type, seen); err != nil {
+			return nil, err
+		}
+	}
+	seen = map[string]bool{}
+	for _, ipRange := range npar.Spec.AllowedIPRanges {
+		if err := validateIPRange(ipRange.CIDR, ipRange.Type, seen); err != nil {
+			return nil, err
 		}
-		if ipRange.Type == "" {
-			return admission.Denied("a connection type must be provided")
+	}
+	seen = map[string]bool{}
+	for _, ipRange := range npar.Spec.ProtectedIPRanges {
+		if err := validateIPRange(ipRange.CIDR, ipRange.Type, seen); err != nil {
+			return nil, err
 		}
 	}
-	return admission.Allowed("")
+	seen = map[string]bool{}
+	for _, ipRange := range npar.Spec.RequiredDenyIPRanges {
+		if err := validateIPRange(ipRange.CIDR, ipRange.Type, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, entity := range npar.Spec.ForbiddenEntities {
+		if err := validateEntity(entity.Entity, entity.Type, seen); err != nil {
+			return nil, err
+		}
+	}
+	seen = map[string]bool{}
+	for _, entity := range npar.Spec.ProtectedEntities {
+		if err := validateEntity(entity.Entity, entity.Type, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := policy.ValidateNamespaceSelector(npar.Spec.NamespaceSelector); err != nil {
+		return nil, fmt.Errorf("an invalid namespace selector was provided: %w", err)
+	}
+	return warnings, nil
 }
 
 func SetupNetworkPolicyAdmissionRuleWebhook(mgr manager.Manager, dec admission.Decoder) {
@@ -45,5 +230,5 @@ func SetupNetworkPolicyAdmissionRuleWebhook(mgr manager.Manager, dec admission.D
 		dec:    dec,
 	}
 	srv := mgr.GetWebhookServer()
-	srv.Register("/validate-tenet-cybozu-io-v1beta2-networkpolicyadmissionrule", &webhook.Admission{Handler: v})
+	srv.Register("/validate-tenet-cybozu-io-v1beta3-networkpolicyadmissionrule", &webhook.Admission{Handler: instrument("networkpolicyadmissionrule", v)})
 }
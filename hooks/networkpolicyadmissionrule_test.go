@@ -6,21 +6,24 @@ import (
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 )
 
 var _ = Describe("NetworkPolicyAdmissionRule webhook", func() {
 	ctx := context.Background()
 
 	It("should deny the creation of a NetworkPolicyAdmissionRule with malformed CIDR", func() {
-		npar := &tenetv1beta2.NetworkPolicyAdmissionRule{
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
 			ObjectMeta: v1.ObjectMeta{
 				Name: uuid.NewString(),
 			},
-			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
-				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
 					{
 						CIDR: "300.300.300.0/12",
 						Type: "all",
@@ -32,16 +35,94 @@ var _ = Describe("NetworkPolicyAdmissionRule webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should deny the creation of a NetworkPolicyAdmissionRule with malformed allowed CIDR", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				AllowedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleAllowedIPRanges{
+					{
+						CIDR: "10.0.0.0/33",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should deny the creation of a NetworkPolicyAdmissionRule with an invalid namespace selector", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					MatchExpressions: []v1.LabelSelectorRequirement{
+						{
+							Key:      "team",
+							Operator: v1.LabelSelectorOpIn,
+						},
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("should deny the creation of a NetworkPolicyAdmissionRule without connection type", func() {
-		npar := &tenetv1beta2.NetworkPolicyAdmissionRule{
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
 			ObjectMeta: v1.ObjectMeta{
 				Name:      uuid.NewString(),
 				Namespace: "default",
 			},
-			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
-				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.0.0.0/24",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should deny the creation of a NetworkPolicyAdmissionRule with an unknown entity", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
+					{
+						Entity: "remote-nodes",
+						Type:   "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should deny the creation of a NetworkPolicyAdmissionRule with duplicate CIDRs", func() {
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
 					{
 						CIDR: "10.0.0.0/24",
+						Type: "egress",
+					},
+					{
+						CIDR: "10.0.0.1/24",
+						Type: "egress",
 					},
 				},
 			},
@@ -50,22 +131,175 @@ var _ = Describe("NetworkPolicyAdmissionRule webhook", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should warn about existing network policies violating a new NetworkPolicyAdmissionRule", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					Namespaces: []string{nsName},
+				},
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		Eventually(func(g Gomega) {
+			err := warningClient.Create(ctx, npar.DeepCopy(), client.DryRunAll)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(warnings.take()).To(ContainElements(
+				"1 existing network policy would violate the rule",
+				ContainSubstring("CiliumNetworkPolicy "+nsName+"/egress-with-forbidden-cidr: "),
+			))
+		}).Should(Succeed())
+	})
+
+	It("should allow updates not changing the spec of a NetworkPolicyAdmissionRule without checking it again", func() {
+		nsName := uuid.NewString()
+		ns := &corev1.Namespace{}
+		ns.Name = nsName
+		err := k8sClient.Create(ctx, ns)
+		Expect(err).NotTo(HaveOccurred())
+		err = createCiliumNetworkPolicy(ctx, nsName, egressForbiddenCIDR)
+		Expect(err).NotTo(HaveOccurred())
+
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+					Namespaces: []string{nsName},
+				},
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		Eventually(func(g Gomega) {
+			err := warningClient.Create(ctx, npar.DeepCopy(), client.DryRunAll)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(warnings.take()).To(ContainElement("1 existing network policy would violate the rule"))
+		}).Should(Succeed())
+		err = warningClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+		warnings.take()
+
+		npar.Labels = map[string]string{"updated": "true"}
+		err = warningClient.Update(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings.take()).To(BeEmpty())
+
+		err = k8sClient.Delete(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
 	It("should allow valid NetworkPolicyAdmissionRules", func() {
-		npar := &tenetv1beta2.NetworkPolicyAdmissionRule{
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{
 			ObjectMeta: v1.ObjectMeta{
 				Name:      uuid.NewString(),
 				Namespace: "default",
 			},
-			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
-				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
 					{
 						CIDR: "10.0.0.0/24",
 						Type: "egress",
 					},
 				},
+				ForbiddenEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
+					{
+						Entity: "host",
+						Type:   "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		// the rule selects every namespace; remove it so that it does not affect other specs.
+		err = k8sClient.Delete(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("NetworkPolicyAdmissionRule conversion", func() {
+	ctx := context.Background()
+
+	It("should validate v1beta2 rules", func() {
+		npar := &tenetv1beta2.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "300.300.300.0/12",
+						Type: "all",
+					},
+				},
 			},
 		}
 		err := k8sClient.Create(ctx, npar)
+		Expect(err).To(MatchError(ContainSubstring("a malformed CIDR string was provided")))
+	})
+
+	It("should serve the audit results of v1beta3 as an ok v1beta2 status", func() {
+		legacy := &tenetv1beta2.NetworkPolicyAdmissionRule{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionRuleSpec{
+				NamespaceSelector: tenetv1beta2.NetworkPolicyAdmissionRuleNamespaceSelector{
+					Namespaces: []string{uuid.NewString()},
+				},
+				ForbiddenIPRanges: []tenetv1beta2.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{
+						CIDR: "10.72.0.0/16",
+						Type: "egress",
+					},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, legacy)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("reading the rule as v1beta3")
+		npar := &tenetv1beta3.NetworkPolicyAdmissionRule{}
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(legacy), npar)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(npar.Spec.ForbiddenIPRanges).To(Equal([]tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{{CIDR: "10.72.0.0/16", Type: "egress"}}))
+
+		By("recording an audit as v1beta3")
+		now := v1.Now()
+		npar.Status.ObservedGeneration = npar.Generation
+		npar.Status.LastAuditTime = &now
+		err = k8sClient.Status().Update(ctx, npar)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("reading the rule back as v1beta2")
+		legacy = &tenetv1beta2.NetworkPolicyAdmissionRule{}
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(npar), legacy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(legacy.Status).To(Equal(tenetv1beta2.NetworkPolicyAdmissionRuleOK))
+
+		err = k8sClient.Delete(ctx, legacy)
 		Expect(err).NotTo(HaveOccurred())
 	})
 })
@@ -0,0 +1,104 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+//+kubebuilder:webhook:path=/validate-tenet-cybozu-io-v1beta3-networkpolicytemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=tenet.cybozu.io,resources=networkpolicytemplates,verbs=create;update,versions=v1beta3,name=vnetworkpolicytemplate.kb.io,admissionReviewVersions={v1}
+
+// lintNamespace is the synthetic namespace templates are rendered against for linting.
+var lintNamespace = &corev1.Namespace{
+	ObjectMeta: v1.ObjectMeta{Name: "tenet-lint"},
+}
+
+type networkPolicyTemplateValidator struct {
+	dec admission.Decoder
+}
+
+var _ admission.Handler = &networkPolicyTemplateValidator{}
+
+// Handle validates the NetworkPolicyTemplate.
+// Updates leaving the spec unchanged, such as adding or removing finalizers, are always allowed
+// so that templates accepted before the webhook existed can still be managed and deleted.
+func (v *networkPolicyTemplateValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	npt := &tenetv1beta3.NetworkPolicyTemplate{}
+	if err := v.dec.Decode(req, npt); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &tenetv1beta3.NetworkPolicyTemplate{}
+		if err := v.dec.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, npt.Spec) {
+			return admission.Allowed("")
+		}
+	}
+
+	if rollout := npt.Spec.Rollout; rollout != nil && rollout.CanarySelector != nil {
+		if _, err := v1.LabelSelectorAsSelector(rollout.CanarySelector); err != nil {
+			return admission.Denied(fmt.Sprintf("an invalid canary selector was provided: %v", err))
+		}
+	}
+
+	warnings, err := v.lint(npt)
+	if err != nil {
+		return admission.Denied(fmt.Sprintf("the policy template is invalid: %v", err))
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// lint parses the template, renders it against a synthetic namespace and checks the result.
+// Only parse errors are returned as errors. The result depends on the metadata of the namespace,
+// which real namespaces may have unlike the synthetic one, so rendering failures and malformed
+// results are returned as warnings, along with warnings about fields the controller overrides.
+func (v *networkPolicyTemplateValidator) lint(npt *tenetv1beta3.NetworkPolicyTemplate) ([]string, error) {
+	if _, err := render.Parse(npt); err != nil {
+		return nil, err
+	}
+	np, err := render.Execute(npt, lintNamespace)
+	if err == nil {
+		err = render.CheckKind(npt, np)
+	}
+	if err == nil {
+		_, err = cilium.DecodePolicy(np)
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("the policy template does not render a valid policy for namespace %s: %v", lintNamespace.Name, err)}, nil
+	}
+
+	var warnings []string
+	if name := np.GetName(); name != "" {
+		warnings = append(warnings, fmt.Sprintf("metadata.name %q is overridden by the controller", name))
+	}
+	if namespace := np.GetNamespace(); namespace != "" {
+		warnings = append(warnings, fmt.Sprintf("metadata.namespace %q is overridden by the controller", namespace))
+	}
+	return warnings, nil
+}
+
+func SetupNetworkPolicyTemplateWebhook(mgr manager.Manager, dec admission.Decoder) {
+	v := &networkPolicyTemplateValidator{
+		dec: dec,
+	}
+	srv := mgr.GetWebhookServer()
+	srv.Register("/validate-tenet-cybozu-io-v1beta3-networkpolicytemplate", &webhook.Admission{Handler: instrument("networkpolicytemplate", v)})
+	// The API server converts NetworkPolicyTemplates between v1beta2 and v1beta3 through this endpoint.
+	srv.Register("/convert", conversion.NewWebhookHandler(mgr.GetScheme(), mgr.GetConverterRegistry()))
+}
@@ -0,0 +1,203 @@
+package hooks
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+const validPolicyTemplate = `apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+spec:
+  endpointSelector: {}
+  egress:
+  - toEndpoints:
+    - matchLabels:
+        "k8s:io.kubernetes.pod.namespace": {{.Name}}
+`
+
+func newNetworkPolicyTemplate(clusterwide bool, policyTemplate string) *tenetv1beta3.NetworkPolicyTemplate {
+	return &tenetv1beta3.NetworkPolicyTemplate{
+		ObjectMeta: v1.ObjectMeta{
+			Name: uuid.NewString(),
+		},
+		Spec: tenetv1beta3.NetworkPolicyTemplateSpec{
+			ClusterWide:    clusterwide,
+			PolicyTemplate: policyTemplate,
+		},
+	}
+}
+
+var _ = Describe("NetworkPolicyTemplate webhook", func() {
+	ctx := context.Background()
+
+	It("should deny templates that cannot be parsed", func() {
+		npt := newNetworkPolicyTemplate(false, "{{.Name")
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).To(MatchError(ContainSubstring("the policy template is invalid")))
+	})
+
+	It("should warn about templates that cannot be rendered", func() {
+		npt := newNetworkPolicyTemplate(false, "{{.Unknown}}")
+		err := warningClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings.take()).To(ContainElement(ContainSubstring("the policy template does not render a valid policy for namespace tenet-lint")))
+	})
+
+	It("should warn about templates rendering a kind not matching clusterwide", func() {
+		npt := newNetworkPolicyTemplate(true, validPolicyTemplate)
+		err := warningClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings.take()).To(ContainElement(ContainSubstring("clusterwide=true")))
+	})
+
+	It("should warn about templates rendering malformed policies", func() {
+		npt := newNetworkPolicyTemplate(false, `apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+spec:
+  egress:
+    toCIDR:
+    - 10.0.0.0/8
+`)
+		err := warningClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings.take()).To(ContainElement(ContainSubstring("malformed CiliumNetworkPolicy")))
+	})
+
+	It("should deny templates with an invalid canary selector", func() {
+		npt := newNetworkPolicyTemplate(false, validPolicyTemplate)
+		npt.Spec.Rollout = &tenetv1beta3.NetworkPolicyTemplateRolloutStrategy{
+			CanarySelector: &v1.LabelSelector{
+				MatchExpressions: []v1.LabelSelectorRequirement{{Key: "canary", Operator: "Unknown"}},
+			},
+		}
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).To(MatchError(ContainSubstring("an invalid canary selector was provided")))
+	})
+
+	It("should warn about names set in templates", func() {
+		npt := newNetworkPolicyTemplate(false, `apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: custom
+spec:
+  endpointSelector: {}
+`)
+		err := warningClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings.take()).To(ContainElement(`metadata.name "custom" is overridden by the controller`))
+	})
+
+	It("should allow valid templates and updates not changing the spec", func() {
+		npt := newNetworkPolicyTemplate(false, validPolicyTemplate)
+		err := k8sClient.Create(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		npt.Labels = map[string]string{"updated": "true"}
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		npt.Spec.PolicyTemplate = "{{.Name"
+		err = k8sClient.Update(ctx, npt)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+// storageVersionHash returns the hash of the storage version of NetworkPolicyTemplates advertised by the API server.
+func storageVersionHash(g Gomega) string {
+	dc, err := discovery.NewDiscoveryClientForConfig(testConfig)
+	g.Expect(err).NotTo(HaveOccurred())
+	resources, err := dc.ServerResourcesForGroupVersion(tenetv1beta3.GroupVersion.String())
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, r := range resources.APIResources {
+		if r.Name == "networkpolicytemplates" {
+			return r.StorageVersionHash
+		}
+	}
+	return ""
+}
+
+// setTemplateStorageVersion makes version the storage version of NetworkPolicyTemplates
+// and waits for the API server to use it.
+func setTemplateStorageVersion(ctx context.Context, version string) {
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	err := k8sClient.Get(ctx, client.ObjectKey{Name: "networkpolicytemplates.tenet.cybozu.io"}, crd)
+	Expect(err).NotTo(HaveOccurred())
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	Expect(err).NotTo(HaveOccurred())
+	changed := false
+	for _, v := range versions {
+		v := v.(map[string]any)
+		storage := v["name"] == version
+		changed = changed || (storage && v["storage"] != true)
+		v["storage"] = storage
+	}
+	if !changed {
+		return
+	}
+	err = unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions")
+	Expect(err).NotTo(HaveOccurred())
+
+	hash := storageVersionHash(Default)
+	err = k8sClient.Update(ctx, crd)
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(func(g Gomega) {
+		g.Expect(storageVersionHash(g)).NotTo(Equal(hash))
+	}).Should(Succeed())
+}
+
+var _ = Describe("NetworkPolicyTemplate conversion", func() {
+	ctx := context.Background()
+
+	It("should serve templates stored with a legacy v1beta2 status", func() {
+		By("storing a template with status ok as v1beta2")
+		setTemplateStorageVersion(ctx, "v1beta2")
+		DeferCleanup(setTemplateStorageVersion, ctx, "v1beta3")
+		legacy := &tenetv1beta2.NetworkPolicyTemplate{
+			ObjectMeta: v1.ObjectMeta{
+				Name: uuid.NewString(),
+			},
+			Spec: tenetv1beta2.NetworkPolicyTemplateSpec{
+				PolicyTemplate: validPolicyTemplate,
+			},
+		}
+		err := k8sClient.Create(ctx, legacy)
+		Expect(err).NotTo(HaveOccurred())
+		legacy.Status = tenetv1beta2.NetworkPolicyTemplateOK
+		err = k8sClient.Status().Update(ctx, legacy)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("upgrading the storage version to v1beta3")
+		setTemplateStorageVersion(ctx, "v1beta3")
+
+		By("reading the template as v1beta3")
+		npt := &tenetv1beta3.NetworkPolicyTemplate{}
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(legacy), npt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(npt.Spec.PolicyTemplate).To(Equal(validPolicyTemplate))
+		Expect(npt.Status.State).To(Equal(tenetv1beta3.NetworkPolicyTemplateOK))
+
+		By("updating the status as v1beta3")
+		npt.Status.ObservedGeneration = npt.Generation
+		err = k8sClient.Status().Update(ctx, npt)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("reading the template back as v1beta2")
+		legacy = &tenetv1beta2.NetworkPolicyTemplate{}
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(npt), legacy)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(legacy.Spec.PolicyTemplate).To(Equal(validPolicyTemplate))
+		Expect(legacy.Status).To(Equal(tenetv1beta2.NetworkPolicyTemplateOK))
+	})
+})
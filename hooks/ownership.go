@@ -0,0 +1,113 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/render"
+)
+
+// generatedPolicyGuard protects network policies generated from NetworkPolicyTemplates against changes by users.
+type generatedPolicyGuard struct {
+	client.Reader
+	serviceAccountName string
+	privileges         *privilegeChecker
+}
+
+func newGeneratedPolicyGuard(mgr manager.Manager, sa string, privileged PrivilegedIdentities) *generatedPolicyGuard {
+	return &generatedPolicyGuard{
+		Reader:             mgr.GetClient(),
+		serviceAccountName: sa,
+		privileges: &privilegeChecker{
+			reader:     mgr.GetAPIReader(),
+			identities: privileged,
+		},
+	}
+}
+
+// templateOwner returns the owner reference of the NetworkPolicyTemplate the policy was generated from, if any.
+func templateOwner(np *unstructured.Unstructured) *v1.OwnerReference {
+	for _, owner := range np.GetOwnerReferences() {
+		if render.IsTemplateOwner(owner) {
+			return &owner
+		}
+	}
+	return nil
+}
+
+// denialReason returns why a deletion or update of a network policy must be denied, or an empty string if it is allowed.
+// newNP is nil for deletions.
+// Generated policies may only be changed by the controller's service account, by privileged identities,
+// or once their template is being deleted or gone. Changes by privileged identities are logged and counted.
+func (g *generatedPolicyGuard) denialReason(ctx context.Context, req admission.Request, oldNP, newNP *unstructured.Unstructured) (string, error) {
+	owner := templateOwner(oldNP)
+	if owner == nil || req.UserInfo.Username == g.serviceAccountName {
+		return "", nil
+	}
+
+	npt := &tenetv1beta3.NetworkPolicyTemplate{}
+	err := g.Get(ctx, client.ObjectKey{Name: owner.Name}, npt)
+	if client.IgnoreNotFound(err) != nil {
+		return "", err
+	}
+	// orphaned policies, and policies of templates being deleted, are left to the garbage collector and to users.
+	if apierrors.IsNotFound(err) || !npt.DeletionTimestamp.IsZero() {
+		return "", nil
+	}
+
+	p, err := g.privileges.privilege(ctx, req.UserInfo)
+	if err != nil {
+		return "", err
+	}
+	if p.identity != "" {
+		log.FromContext(ctx).Info("allowing change of generated policy by privileged identity",
+			"identity", p.identity, "privilege", p.class, "user", req.UserInfo.Username, "operation", req.Operation,
+			"kind", oldNP.GetKind(), "namespace", oldNP.GetNamespace(), "name", oldNP.GetName())
+		privilegedChangesTotal.WithLabelValues(p.class, string(req.Operation), oldNP.GetKind()).Inc()
+		return "", nil
+	}
+
+	switch {
+	case newNP == nil:
+		return "user deletion is not allowed", nil
+	case templateOwner(newNP) == nil:
+		return fmt.Sprintf("removing the owner reference to NetworkPolicyTemplate %s is not allowed", owner.Name), nil
+	default:
+		return fmt.Sprintf("user modification is not allowed: the policy is generated from NetworkPolicyTemplate %s", owner.Name), nil
+	}
+}
+
+// handleDeleteOrUpdate denies deletions and updates of generated policies by users.
+// newObject returns an empty object of the kind of the policy. It returns false if the request is not denied.
+func (g *generatedPolicyGuard) handleDeleteOrUpdate(ctx context.Context, req admission.Request, dec admission.Decoder, newObject func() *unstructured.Unstructured) (admission.Response, bool) {
+	oldNP := newObject()
+	if err := dec.DecodeRaw(req.OldObject, oldNP); err != nil {
+		return admission.Errored(http.StatusBadRequest, err), true
+	}
+	var newNP *unstructured.Unstructured
+	if req.Operation == admissionv1.Update {
+		newNP = newObject()
+		if err := dec.DecodeRaw(req.Object, newNP); err != nil {
+			return admission.Errored(http.StatusBadRequest, err), true
+		}
+	}
+	reason, err := g.denialReason(ctx, req, oldNP, newNP)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err), true
+	}
+	if reason != "" {
+		return admission.Denied(reason), true
+	}
+	return admission.Response{}, false
+}
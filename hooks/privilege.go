@@ -0,0 +1,98 @@
+package hooks
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Keys of the ConfigMap listing privileged identities.
+// Values hold identities separated by newlines or spaces.
+const (
+	PrivilegedUsersKey           = "users"
+	PrivilegedGroupsKey          = "groups"
+	PrivilegedServiceAccountsKey = "serviceAccounts"
+)
+
+// PrivilegedIdentities lists the identities, besides the controller's service account,
+// allowed to change network policies generated from NetworkPolicyTemplates.
+type PrivilegedIdentities struct {
+	// Users are user names, e.g. admin.
+	Users []string
+	// Groups are group names, e.g. system:masters.
+	Groups []string
+	// ServiceAccounts are service accounts in the <namespace>:<name> form.
+	ServiceAccounts []string
+	// ConfigMap optionally names a ConfigMap listing additional identities. It is read on each privileged request.
+	ConfigMap types.NamespacedName
+}
+
+// Classes of privileges, by which changes made by privileged identities are counted.
+const (
+	privilegeUser           = "user"
+	privilegeGroup          = "group"
+	privilegeServiceAccount = "serviceaccount"
+	privilegeConfigMap      = "configmap"
+)
+
+// privilege is a privileged identity matched by a user, along with the class of the privilege.
+// The zero value means the user is not privileged.
+type privilege struct {
+	identity string
+	class    string
+}
+
+// match returns the privilege the user matches, if any.
+func (p PrivilegedIdentities) match(user authenticationv1.UserInfo) privilege {
+	if slices.Contains(p.Users, user.Username) {
+		return privilege{identity: user.Username, class: privilegeUser}
+	}
+	for _, sa := range p.ServiceAccounts {
+		if user.Username == "system:serviceaccount:"+sa {
+			return privilege{identity: user.Username, class: privilegeServiceAccount}
+		}
+	}
+	for _, group := range user.Groups {
+		if slices.Contains(p.Groups, group) {
+			return privilege{identity: "group:" + group, class: privilegeGroup}
+		}
+	}
+	return privilege{}
+}
+
+// privilegeChecker resolves whether a user is privileged, reading the configured ConfigMap if any.
+type privilegeChecker struct {
+	// reader should not be cached to avoid watching all ConfigMaps.
+	reader     client.Reader
+	identities PrivilegedIdentities
+}
+
+// privilege returns the privilege the user matches, if any.
+// Identities listed in the ConfigMap are of the configmap class.
+func (c *privilegeChecker) privilege(ctx context.Context, user authenticationv1.UserInfo) (privilege, error) {
+	if p := c.identities.match(user); p.identity != "" {
+		return p, nil
+	}
+	if c.identities.ConfigMap.Name == "" {
+		return privilege{}, nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.reader.Get(ctx, c.identities.ConfigMap, cm); err != nil {
+		return privilege{}, client.IgnoreNotFound(err)
+	}
+	fromConfigMap := PrivilegedIdentities{
+		Users:           strings.Fields(cm.Data[PrivilegedUsersKey]),
+		Groups:          strings.Fields(cm.Data[PrivilegedGroupsKey]),
+		ServiceAccounts: strings.Fields(cm.Data[PrivilegedServiceAccountsKey]),
+	}
+	p := fromConfigMap.match(user)
+	if p.identity != "" {
+		p.class = privilegeConfigMap
+	}
+	return p, nil
+}
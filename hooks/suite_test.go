@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,7 +15,9 @@ import (
 
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -25,6 +28,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -32,9 +36,34 @@ import (
 // http://onsi.github.io/ginkgo/ to learn more about Ginkgo.
 
 var k8sClient client.Client
+var testConfig *rest.Config
+var testScheme *runtime.Scheme
+var warningClient client.Client
+var warnings = &warningRecorder{}
 var testEnv *envtest.Environment
 var cancelMgr context.CancelFunc
 
+// warningRecorder records the warnings returned by the API server.
+type warningRecorder struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (r *warningRecorder) HandleWarningHeader(code int, agent string, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, text)
+}
+
+// take returns the recorded warnings and clears them.
+func (r *warningRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ws := r.warnings
+	r.warnings = nil
+	return ws
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -47,6 +76,19 @@ var _ = BeforeSuite(func() {
 	ctx, cancel := context.WithCancel(context.TODO())
 	cancelMgr = cancel
 
+	scheme := runtime.NewScheme()
+	err := clientgoscheme.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = tenetv1beta2.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = tenetv1beta3.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = admissionv1beta1.AddToScheme(scheme)
+	Expect(err).NotTo(HaveOccurred())
+
+	//+kubebuilder:scaffold:scheme
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
 		CRDDirectoryPaths: []string{
@@ -54,6 +96,8 @@ var _ = BeforeSuite(func() {
 			filepath.Join("..", "test", "crd"),
 		},
 		ErrorIfCRDPathMissing: false,
+		// The scheme lets envtest configure the conversion webhook of NetworkPolicyTemplates.
+		Scheme: scheme,
 		WebhookInstallOptions: envtest.WebhookInstallOptions{
 			Paths: []string{filepath.Join("..", "config", "webhook")},
 		},
@@ -63,21 +107,17 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
-	scheme := runtime.NewScheme()
-	err = clientgoscheme.AddToScheme(scheme)
-	Expect(err).NotTo(HaveOccurred())
-	err = tenetv1beta2.AddToScheme(scheme)
-	Expect(err).NotTo(HaveOccurred())
-
-	err = admissionv1beta1.AddToScheme(scheme)
-	Expect(err).NotTo(HaveOccurred())
-
-	//+kubebuilder:scaffold:scheme
-
+	testConfig = cfg
+	testScheme = scheme
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	warningCfg := rest.CopyConfig(cfg)
+	warningCfg.WarningHandler = warnings
+	warningClient, err = client.New(warningCfg, client.Options{Scheme: scheme})
+	Expect(err).NotTo(HaveOccurred())
+
 	// start webhook server using Manager
 	webhookInstallOptions := &testEnv.WebhookInstallOptions
 	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
@@ -96,7 +136,13 @@ var _ = BeforeSuite(func() {
 
 	dec := admission.NewDecoder(scheme)
 	SetupNetworkPolicyAdmissionRuleWebhook(mgr, dec)
-	SetupCiliumNetworkPolicyWebhook(mgr, dec, "system:serviceaccount:tenet-system:tenet-controller-manager")
+	SetupNetworkPolicyTemplateWebhook(mgr, dec)
+	privileged := PrivilegedIdentities{
+		Groups:    []string{"tenet:break-glass"},
+		ConfigMap: types.NamespacedName{Namespace: "default", Name: "tenet-privileged-identities"},
+	}
+	SetupCiliumNetworkPolicyWebhook(mgr, dec, "system:serviceaccount:tenet-system:tenet-controller-manager", privileged)
+	SetupCiliumClusterwideNetworkPolicyWebhook(mgr, dec, "system:serviceaccount:tenet-system:tenet-controller-manager", privileged)
 
 	go func() {
 		err = mgr.Start(ctx)
@@ -118,6 +164,18 @@ var _ = BeforeSuite(func() {
 	}).Should(Succeed())
 })
 
+// impersonatingClient returns a client acting as the user with cluster-admin privileges and the given groups.
+func impersonatingClient(user string, groups ...string) client.Client {
+	cfg := rest.CopyConfig(testConfig)
+	cfg.Impersonate = rest.ImpersonationConfig{
+		UserName: user,
+		Groups:   append([]string{"system:masters"}, groups...),
+	}
+	c, err := client.New(cfg, client.Options{Scheme: testScheme})
+	Expect(err).NotTo(HaveOccurred())
+	return c
+}
+
 var _ = AfterSuite(func() {
 	cancelMgr()
 	time.Sleep(50 * time.Millisecond)
@@ -0,0 +1,76 @@
+package cilium
+
+import "slices"
+
+// Entity names understood by Cilium's toEntities and fromEntities selectors.
+const (
+	EntityAll           = "all"
+	EntityWorld         = "world"
+	EntityWorldIPv4     = "world-ipv4"
+	EntityWorldIPv6     = "world-ipv6"
+	EntityCluster       = "cluster"
+	EntityHost          = "host"
+	EntityRemoteNode    = "remote-node"
+	EntityKubeAPIServer = "kube-apiserver"
+	EntityIngress       = "ingress"
+	EntityHealth        = "health"
+	EntityInit          = "init"
+	EntityUnmanaged     = "unmanaged"
+	EntityNone          = "none"
+)
+
+// entities lists all entity names.
+var entities = []string{
+	EntityAll,
+	EntityWorld,
+	EntityWorldIPv4,
+	EntityWorldIPv6,
+	EntityCluster,
+	EntityHost,
+	EntityRemoteNode,
+	EntityKubeAPIServer,
+	EntityIngress,
+	EntityHealth,
+	EntityInit,
+	EntityUnmanaged,
+	EntityNone,
+}
+
+// IsEntity reports whether name is an entity known to Cilium.
+func IsEntity(name string) bool {
+	return slices.Contains(entities, name)
+}
+
+// entityChildren maps an entity to the entities it directly contains.
+var entityChildren = map[string][]string{
+	EntityAll:   {EntityWorld, EntityCluster},
+	EntityWorld: {EntityWorldIPv4, EntityWorldIPv6},
+	EntityCluster: {
+		EntityHost,
+		EntityRemoteNode,
+		EntityKubeAPIServer,
+		EntityIngress,
+		EntityHealth,
+		EntityInit,
+		EntityUnmanaged,
+	},
+}
+
+// EntityContains reports whether entity a is or contains entity b.
+func EntityContains(a, b string) bool {
+	if a == b {
+		return true
+	}
+	for _, child := range entityChildren[a] {
+		if EntityContains(child, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// EntitiesOverlap reports whether a and b select at least one common peer,
+// i.e. one of them is, contains, or is contained in the other.
+func EntitiesOverlap(a, b string) bool {
+	return EntityContains(a, b) || EntityContains(b, a)
+}
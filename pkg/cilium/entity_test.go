@@ -0,0 +1,42 @@
+package cilium
+
+import "testing"
+
+func TestEntitiesOverlap(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{EntityWorld, EntityWorld, true},
+		{EntityWorld, EntityWorldIPv4, true},
+		{EntityWorldIPv6, EntityWorld, true},
+		{EntityWorld, EntityAll, true},
+		{EntityCluster, EntityHost, true},
+		{EntityKubeAPIServer, EntityAll, true},
+		{EntityWorld, EntityCluster, false},
+		{EntityWorldIPv4, EntityWorldIPv6, false},
+		{EntityHost, EntityRemoteNode, false},
+		{EntityNone, EntityAll, false},
+	}
+	for _, tc := range cases {
+		if got := EntitiesOverlap(tc.a, tc.b); got != tc.want {
+			t.Errorf("EntitiesOverlap(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestIsEntity(t *testing.T) {
+	cases := map[string]bool{
+		EntityWorld:      true,
+		EntityRemoteNode: true,
+		EntityNone:       true,
+		"remote-nodes":   false,
+		"World":          false,
+		"":               false,
+	}
+	for name, want := range cases {
+		if got := IsEntity(name); got != want {
+			t.Errorf("IsEntity(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
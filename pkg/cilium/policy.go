@@ -0,0 +1,181 @@
+package cilium
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Policy is the subset of a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy that tenet evaluates.
+// Fields outside of the subset, such as L7 rules, are ignored.
+type Policy struct {
+	// Spec is the single rule of the policy.
+	Spec *Rule `json:"spec,omitempty"`
+	// Specs is the list of rules of the policy.
+	Specs []Rule `json:"specs,omitempty"`
+}
+
+// Rule is a rule of a policy, selecting endpoints and the connections allowed or denied for them.
+type Rule struct {
+	EndpointSelector *v1.LabelSelector `json:"endpointSelector,omitempty"`
+	NodeSelector     *v1.LabelSelector `json:"nodeSelector,omitempty"`
+	Ingress          []Ingress         `json:"ingress,omitempty"`
+	IngressDeny      []Ingress         `json:"ingressDeny,omitempty"`
+	Egress           []Egress          `json:"egress,omitempty"`
+	EgressDeny       []Egress          `json:"egressDeny,omitempty"`
+	Description      string            `json:"description,omitempty"`
+}
+
+// Ingress selects the peers connections are allowed or denied from.
+type Ingress struct {
+	FromEndpoints []v1.LabelSelector `json:"fromEndpoints,omitempty"`
+	FromCIDR      []string           `json:"fromCIDR,omitempty"`
+	FromCIDRSet   []CIDRRule         `json:"fromCIDRSet,omitempty"`
+	FromEntities  []string           `json:"fromEntities,omitempty"`
+	ToPorts       []PortRule         `json:"toPorts,omitempty"`
+}
+
+// Egress selects the peers connections are allowed or denied to.
+type Egress struct {
+	ToEndpoints []v1.LabelSelector `json:"toEndpoints,omitempty"`
+	ToCIDR      []string           `json:"toCIDR,omitempty"`
+	ToCIDRSet   []CIDRRule         `json:"toCIDRSet,omitempty"`
+	ToEntities  []string           `json:"toEntities,omitempty"`
+	ToFQDNs     []FQDNSelector     `json:"toFQDNs,omitempty"`
+	ToServices  []Service          `json:"toServices,omitempty"`
+	ToPorts     []PortRule         `json:"toPorts,omitempty"`
+}
+
+// CIDRRule selects an IP range, except some of its sub-ranges.
+// CIDR is empty when the range is given by a CiliumCIDRGroup.
+type CIDRRule struct {
+	CIDR         string   `json:"cidr,omitempty"`
+	CIDRGroupRef string   `json:"cidrGroupRef,omitempty"`
+	Except       []string `json:"except,omitempty"`
+}
+
+// FQDNSelector selects peers by DNS name.
+type FQDNSelector struct {
+	MatchName    string `json:"matchName,omitempty"`
+	MatchPattern string `json:"matchPattern,omitempty"`
+}
+
+// String returns the name or the pattern selected.
+func (s FQDNSelector) String() string {
+	if s.MatchName != "" {
+		return s.MatchName
+	}
+	return s.MatchPattern
+}
+
+// Service selects the backends of Kubernetes services.
+type Service struct {
+	K8sService         *K8sServiceNamespace         `json:"k8sService,omitempty"`
+	K8sServiceSelector *K8sServiceSelectorNamespace `json:"k8sServiceSelector,omitempty"`
+}
+
+// K8sServiceNamespace selects a service by name.
+type K8sServiceNamespace struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+}
+
+// K8sServiceSelectorNamespace selects services by labels.
+type K8sServiceSelectorNamespace struct {
+	Selector  v1.LabelSelector `json:"selector"`
+	Namespace string           `json:"namespace,omitempty"`
+}
+
+// PortRule restricts connections to ports.
+type PortRule struct {
+	Ports []PortProtocol `json:"ports,omitempty"`
+}
+
+// PortProtocol is a port, or a range of ports, and a protocol.
+type PortProtocol struct {
+	Port     string `json:"port,omitempty"`
+	EndPort  int32  `json:"endPort,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Peers are the peers selected by IP ranges, entities or DNS names in an ingress or egress rule.
+type Peers struct {
+	CIDR     []string
+	CIDRSet  []CIDRRule
+	Entities []string
+	FQDNs    []FQDNSelector
+}
+
+// Peers returns the peers selected by the rule.
+func (r Ingress) Peers() Peers {
+	return Peers{CIDR: r.FromCIDR, CIDRSet: r.FromCIDRSet, Entities: r.FromEntities}
+}
+
+// Peers returns the peers selected by the rule.
+func (r Egress) Peers() Peers {
+	return Peers{CIDR: r.ToCIDR, CIDRSet: r.ToCIDRSet, Entities: r.ToEntities, FQDNs: r.ToFQDNs}
+}
+
+// Section returns the peers of each rule of a section of the rule, e.g. egressDeny.
+func (r *Rule) Section(t RuleType) []Peers {
+	var peers []Peers
+	switch t.Type {
+	case EgressRule.Type:
+		for _, er := range r.Egress {
+			peers = append(peers, er.Peers())
+		}
+	case EgressDenyRule.Type:
+		for _, er := range r.EgressDeny {
+			peers = append(peers, er.Peers())
+		}
+	case IngressRule.Type:
+		for _, ir := range r.Ingress {
+			peers = append(peers, ir.Peers())
+		}
+	case IngressDenyRule.Type:
+		for _, ir := range r.IngressDeny {
+			peers = append(peers, ir.Peers())
+		}
+	}
+	return peers
+}
+
+// PathRule is a rule of a policy along with its JSON path, e.g. specs[1].
+type PathRule struct {
+	*Rule
+	Path string
+}
+
+// Rules returns the rules of the policy in document order.
+func (p *Policy) Rules() []PathRule {
+	var rules []PathRule
+	if p.Spec != nil {
+		rules = append(rules, PathRule{Rule: p.Spec, Path: "spec"})
+	}
+	for i := range p.Specs {
+		rules = append(rules, PathRule{Rule: &p.Specs[i], Path: fmt.Sprintf("specs[%d]", i)})
+	}
+	return rules
+}
+
+// DecodePolicy decodes the rules of a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy.
+// It fails if a field of the subset has an unexpected type.
+func DecodePolicy(np *unstructured.Unstructured) (*Policy, error) {
+	content := map[string]any{}
+	for _, key := range []string{"spec", "specs"} {
+		if v, ok := np.Object[key]; ok {
+			content[key] = v
+		}
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("malformed %s %s: %w", np.GetKind(), np.GetName(), err)
+	}
+	return p, nil
+}
@@ -0,0 +1,100 @@
+package cilium
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func decode(t *testing.T, manifest string) (*Policy, error) {
+	t.Helper()
+	np := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &np.Object); err != nil {
+		t.Fatal(err)
+	}
+	return DecodePolicy(np)
+}
+
+func TestDecodePolicy(t *testing.T) {
+	p, err := decode(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  endpointSelector: {}
+  egress:
+  - toCIDRSet:
+    - cidr: 10.0.0.0/8
+      except:
+      - 10.72.0.0/16
+    - cidrGroupRef: bmc
+    toPorts:
+    - ports:
+      - port: "443"
+        protocol: TCP
+  - toFQDNs:
+    - matchPattern: "*.example.com"
+  - toServices:
+    - k8sService:
+        serviceName: api
+        namespace: default
+specs:
+- endpointSelector: {}
+  ingressDeny:
+  - fromEntities:
+    - world
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := p.Rules()
+	if len(rules) != 2 || rules[0].Path != "spec" || rules[1].Path != "specs[0]" {
+		t.Fatalf("unexpected rules %v", rules)
+	}
+
+	egress := rules[0].Section(EgressRule)
+	if len(egress) != 3 {
+		t.Fatalf("expected 3 egress rules, got %v", egress)
+	}
+	if sets := egress[0].CIDRSet; len(sets) != 2 || sets[0].CIDR != "10.0.0.0/8" || sets[0].Except[0] != "10.72.0.0/16" || sets[1].CIDRGroupRef != "bmc" {
+		t.Errorf("unexpected CIDRSet %v", sets)
+	}
+	if ports := rules[0].Egress[0].ToPorts; len(ports) != 1 || ports[0].Ports[0].Port != "443" {
+		t.Errorf("unexpected ports %v", ports)
+	}
+	if fqdns := rules[0].Egress[1].ToFQDNs; len(fqdns) != 1 || fqdns[0].MatchPattern != "*.example.com" {
+		t.Errorf("unexpected FQDNs %v", fqdns)
+	}
+	if svcs := rules[0].Egress[2].ToServices; len(svcs) != 1 || svcs[0].K8sService.ServiceName != "api" {
+		t.Errorf("unexpected services %v", svcs)
+	}
+
+	if deny := rules[1].Section(IngressDenyRule); len(deny) != 1 || deny[0].Entities[0] != EntityWorld {
+		t.Errorf("unexpected ingressDeny rules %v", deny)
+	}
+	if ingress := rules[1].Section(IngressRule); len(ingress) != 0 {
+		t.Errorf("unexpected ingress rules %v", ingress)
+	}
+}
+
+func TestDecodeMalformedPolicy(t *testing.T) {
+	_, err := decode(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  egress:
+    toCIDR:
+    - 10.0.0.0/8
+`)
+	if err == nil {
+		t.Fatal("decoding a malformed policy should fail")
+	}
+	if !strings.Contains(err.Error(), "CiliumNetworkPolicy test") {
+		t.Errorf("error should name the policy: %v", err)
+	}
+}
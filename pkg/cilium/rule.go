@@ -2,7 +2,12 @@ package cilium
 
 // RuleType specifies lookup values for CIDR-based policies.
 type RuleType struct {
-	Type     string
+	// Type is the key of the rule section in a policy spec.
+	Type string
+	// Direction is the direction of connections the section applies to, either "egress" or "ingress".
+	Direction string
+	// Deny indicates whether the section denies the selected connections instead of allowing them.
+	Deny     bool
 	RuleKeys map[RuleKey]string
 }
 
@@ -12,23 +17,45 @@ const (
 	CIDRRuleKey    RuleKey = "cidr"
 	CIDRSetRuleKey RuleKey = "cidrset"
 	EntityRuleKey  RuleKey = "entity"
+	FQDNRuleKey    RuleKey = "fqdn"
 )
 
 var (
+	egressRuleKeys = map[RuleKey]string{
+		CIDRRuleKey:    "toCIDR",
+		CIDRSetRuleKey: "toCIDRSet",
+		EntityRuleKey:  "toEntities",
+		FQDNRuleKey:    "toFQDNs",
+	}
+	ingressRuleKeys = map[RuleKey]string{
+		CIDRRuleKey:    "fromCIDR",
+		CIDRSetRuleKey: "fromCIDRSet",
+		EntityRuleKey:  "fromEntities",
+	}
+
 	EgressRule = RuleType{
-		Type: "egress",
-		RuleKeys: map[RuleKey]string{
-			CIDRRuleKey:    "toCIDR",
-			CIDRSetRuleKey: "toCIDRSet",
-			EntityRuleKey:  "toEntities",
-		},
+		Type:      "egress",
+		Direction: "egress",
+		RuleKeys:  egressRuleKeys,
 	}
 	IngressRule = RuleType{
-		Type: "ingress",
-		RuleKeys: map[RuleKey]string{
-			CIDRRuleKey:    "fromCIDR",
-			CIDRSetRuleKey: "fromCIDRSet",
-			EntityRuleKey:  "fromEntities",
-		},
+		Type:      "ingress",
+		Direction: "ingress",
+		RuleKeys:  ingressRuleKeys,
 	}
+	EgressDenyRule = RuleType{
+		Type:      "egressDeny",
+		Direction: "egress",
+		Deny:      true,
+		RuleKeys:  egressRuleKeys,
+	}
+	IngressDenyRule = RuleType{
+		Type:      "ingressDeny",
+		Direction: "ingress",
+		Deny:      true,
+		RuleKeys:  ingressRuleKeys,
+	}
+
+	// RuleTypes lists all rule sections of a policy spec in evaluation order.
+	RuleTypes = []RuleType{EgressRule, IngressRule, EgressDenyRule, IngressDenyRule}
 )
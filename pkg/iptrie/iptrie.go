@@ -0,0 +1,69 @@
+package iptrie
+
+import "net/netip"
+
+// Trie is a binary prefix trie mapping IP ranges to values.
+// IPv4 and IPv6 ranges are stored in separate trees, as they never overlap;
+// IPv4-mapped IPv6 ranges are stored as IPv4 ranges.
+// The zero value is an empty trie ready to use.
+type Trie[T any] struct {
+	roots [2]node[T]
+}
+
+type node[T any] struct {
+	children [2]*node[T]
+	values   []T
+}
+
+// Insert adds a value for the given IP range.
+func (t *Trie[T]) Insert(prefix netip.Prefix, value T) {
+	p := Canonical(prefix)
+	n := t.root(p)
+	for i := range p.Bits() {
+		b := bit(p.Addr(), i)
+		if n.children[b] == nil {
+			n.children[b] = &node[T]{}
+		}
+		n = n.children[b]
+	}
+	n.values = append(n.values, value)
+}
+
+// Overlapping returns the values of the ranges that contain or are contained in the given IP range.
+func (t *Trie[T]) Overlapping(prefix netip.Prefix) []T {
+	p := Canonical(prefix)
+	n := t.root(p)
+	var values []T
+	for i := range p.Bits() {
+		values = append(values, n.values...)
+		n = n.children[bit(p.Addr(), i)]
+		if n == nil {
+			return values
+		}
+	}
+	return n.collect(values)
+}
+
+// root returns the root of the tree of the family of p.
+func (t *Trie[T]) root(p netip.Prefix) *node[T] {
+	if p.Addr().Is4() {
+		return &t.roots[0]
+	}
+	return &t.roots[1]
+}
+
+// collect appends the values of n and all its descendants.
+func (n *node[T]) collect(values []T) []T {
+	values = append(values, n.values...)
+	for _, child := range n.children {
+		if child != nil {
+			values = child.collect(values)
+		}
+	}
+	return values
+}
+
+func bit(addr netip.Addr, i int) int {
+	b := addr.AsSlice()
+	return int(b[i/8]>>(7-i%8)) & 1
+}
@@ -0,0 +1,148 @@
+package iptrie
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestOverlapping(t *testing.T) {
+	var trie Trie[string]
+	for _, s := range []string{
+		"10.0.0.0/8",
+		"10.72.16.0/20",
+		"10.72.16.0/24",
+		"192.168.0.0/16",
+		"0.0.0.0/0",
+		"fd00::/8",
+		"fd00:1::/32",
+	} {
+		trie.Insert(netip.MustParsePrefix(s), s)
+	}
+
+	cases := []struct {
+		cidr string
+		want []string
+	}{
+		{"10.72.16.0/22", []string{"0.0.0.0/0", "10.0.0.0/8", "10.72.16.0/20", "10.72.16.0/24"}},
+		{"10.72.0.0/16", []string{"0.0.0.0/0", "10.0.0.0/8", "10.72.16.0/20", "10.72.16.0/24"}},
+		{"10.1.0.0/16", []string{"0.0.0.0/0", "10.0.0.0/8"}},
+		{"172.16.0.0/12", []string{"0.0.0.0/0"}},
+		{"0.0.0.0/0", []string{"0.0.0.0/0", "10.0.0.0/8", "10.72.16.0/20", "10.72.16.0/24", "192.168.0.0/16"}},
+		{"fd00:1:2::/48", []string{"fd00:1::/32", "fd00::/8"}},
+		{"fe80::/10", nil},
+		{"::ffff:10.72.16.0/120", []string{"0.0.0.0/0", "10.0.0.0/8", "10.72.16.0/20", "10.72.16.0/24"}},
+		{"::ffff:10.0.0.0/104", []string{"0.0.0.0/0", "10.0.0.0/8", "10.72.16.0/20", "10.72.16.0/24"}},
+		{"::/0", []string{"fd00:1::/32", "fd00::/8"}},
+		{"::/64", nil},
+	}
+	for _, tc := range cases {
+		got := trie.Overlapping(netip.MustParsePrefix(tc.cidr))
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Overlapping(%s) = %v, want %v", tc.cidr, got, tc.want)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	cases := map[string]string{
+		"10.72.16.1/20":         "10.72.16.0/20",
+		"::ffff:10.0.0.0/104":   "10.0.0.0/8",
+		"::ffff:10.72.16.1/128": "10.72.16.1/32",
+		"::ffff:0.0.0.0/96":     "0.0.0.0/0",
+		"::ffff:0.0.0.0/95":     "::fffe:0:0/95",
+		"fd00::1/8":             "fd00::/8",
+	}
+	for in, want := range cases {
+		got, err := ParsePrefix(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want {
+			t.Errorf("ParsePrefix(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"10.0.0.0/8", "10.72.16.0/20", true},
+		{"10.0.0.0/8", "11.0.0.0/8", false},
+		{"10.0.0.0/8", "::ffff:10.1.0.0/112", true},
+		{"10.0.0.0/8", "::ffff:11.0.0.0/104", false},
+		{"10.0.0.0/8", "::/0", false},
+		{"10.0.0.0/8", "::/64", false},
+		{"0.0.0.0/0", "::/0", false},
+		{"10.0.0.0/8", "64:ff9b::/96", false},
+		{"10.0.0.0/8", "fd00::/8", false},
+		{"fd00::/8", "fd00:1::/32", true},
+	}
+	for _, tc := range cases {
+		a, b := netip.MustParsePrefix(tc.a), netip.MustParsePrefix(tc.b)
+		if got := Overlaps(a, b); got != tc.want {
+			t.Errorf("Overlaps(%s, %s) = %v, want %v", a, b, got, tc.want)
+		}
+		if got := Overlaps(b, a); got != tc.want {
+			t.Errorf("Overlaps(%s, %s) = %v, want %v", b, a, got, tc.want)
+		}
+	}
+}
+
+func TestCovered(t *testing.T) {
+	ranges := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/9"),
+		netip.MustParsePrefix("10.128.0.0/9"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	cases := []struct {
+		cidr string
+		want bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.1.0.0/16", true},
+		{"::ffff:10.0.0.0/104", true},
+		{"::ffff:10.1.2.3/128", true},
+		{"fd00:1::/32", true},
+		{"8.0.0.0/6", false},
+		{"::ffff:11.0.0.0/104", false},
+		{"::/0", false},
+		{"::/64", false},
+	}
+	for _, tc := range cases {
+		if got := Covered(netip.MustParsePrefix(tc.cidr), ranges); got != tc.want {
+			t.Errorf("Covered(%s) = %v, want %v", tc.cidr, got, tc.want)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	cases := []struct {
+		cidr   string
+		ranges []string
+		want   []string
+	}{
+		{"10.0.0.0/8", nil, []string{"10.0.0.0/8"}},
+		{"10.0.0.0/8", []string{"192.168.0.0/16"}, []string{"10.0.0.0/8"}},
+		{"10.0.0.0/8", []string{"0.0.0.0/0"}, nil},
+		{"10.0.0.0/8", []string{"10.128.0.0/9"}, []string{"10.0.0.0/9"}},
+		{"10.0.0.0/14", []string{"10.1.0.0/16", "::ffff:10.3.0.0/112"}, []string{"10.0.0.0/16", "10.2.0.0/16"}},
+		{"::ffff:10.0.0.0/104", []string{"10.0.0.0/9"}, []string{"10.128.0.0/9"}},
+	}
+	for _, tc := range cases {
+		var ranges []netip.Prefix
+		for _, r := range tc.ranges {
+			ranges = append(ranges, netip.MustParsePrefix(r))
+		}
+		var got []string
+		for _, p := range Subtract(netip.MustParsePrefix(tc.cidr), ranges) {
+			got = append(got, p.String())
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Subtract(%s, %v) = %v, want %v", tc.cidr, tc.ranges, got, tc.want)
+		}
+	}
+}
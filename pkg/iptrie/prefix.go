@@ -0,0 +1,85 @@
+package iptrie
+
+import "net/netip"
+
+// ParsePrefix parses s as an IP range in CIDR notation and returns its canonical form.
+// Unlike netip.ParsePrefix, host bits are allowed and cleared.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return Canonical(p), nil
+}
+
+// Canonical returns p with its host bits cleared, and IPv4-mapped IPv6 ranges such as
+// ::ffff:10.0.0.0/104 converted to the equivalent IPv4 range, e.g. 10.0.0.0/8.
+func Canonical(p netip.Prefix) netip.Prefix {
+	if p.Addr().Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked()
+}
+
+// Overlaps reports whether a and b have at least one address in common.
+// IPv4-mapped IPv6 ranges are compared as IPv4 ranges; other IPv6 ranges never overlap IPv4 ranges.
+func Overlaps(a, b netip.Prefix) bool {
+	a, b = Canonical(a), Canonical(b)
+	return a.Addr().Is4() == b.Addr().Is4() && a.Overlaps(b)
+}
+
+// Contains reports whether a contains every address of b.
+func Contains(a, b netip.Prefix) bool {
+	a, b = Canonical(a), Canonical(b)
+	return a.Addr().Is4() == b.Addr().Is4() && a.Bits() <= b.Bits() && a.Contains(b.Addr())
+}
+
+// Covered reports whether p is fully contained in the union of the given ranges.
+func Covered(p netip.Prefix, ranges []netip.Prefix) bool {
+	p = Canonical(p)
+	overlapping := false
+	for _, r := range ranges {
+		if Contains(r, p) {
+			return true
+		}
+		if Overlaps(r, p) {
+			overlapping = true
+		}
+	}
+	if !overlapping || p.IsSingleIP() {
+		return false
+	}
+	// p is partially covered; check both of its halves.
+	lower, upper := halves(p)
+	return Covered(lower, ranges) && Covered(upper, ranges)
+}
+
+// Subtract returns the ranges covering the addresses of p that are not in any of the given ranges.
+func Subtract(p netip.Prefix, ranges []netip.Prefix) []netip.Prefix {
+	p = Canonical(p)
+	overlapping := false
+	for _, r := range ranges {
+		if Contains(r, p) {
+			return nil
+		}
+		if Overlaps(r, p) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return []netip.Prefix{p}
+	}
+	// p is partially excluded, so it cannot be a single address; subtract from both of its halves.
+	lower, upper := halves(p)
+	return append(Subtract(lower, ranges), Subtract(upper, ranges)...)
+}
+
+// halves splits the canonical range p, which must not be a single address, into its lower and upper halves.
+func halves(p netip.Prefix) (netip.Prefix, netip.Prefix) {
+	lower := netip.PrefixFrom(p.Addr(), p.Bits()+1)
+	b := p.Addr().AsSlice()
+	b[p.Bits()/8] |= 0x80 >> (p.Bits() % 8)
+	addr, _ := netip.AddrFromSlice(b)
+	upper := netip.PrefixFrom(addr, p.Bits()+1)
+	return lower, upper
+}
@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+)
+
+// PolicyViolations holds the violations found in an existing network policy.
+type PolicyViolations struct {
+	// Policy is the offending CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy.
+	Policy *unstructured.Unstructured
+	// Violations lists the violations found in the policy.
+	Violations []Violation
+}
+
+// FindViolations evaluates all existing CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies
+// against the rules and returns the policies violating them.
+// Violations allowed by active NetworkPolicyAdmissionExceptions are not reported.
+// Policies that cannot be evaluated are logged and skipped.
+// The rules are evaluated in a single pass, and the Rule of every violation points to an element of rules.
+// It returns the error of ctx if ctx is done before all policies are evaluated.
+func FindViolations(ctx context.Context, c client.Reader, rules []tenetv1beta3.NetworkPolicyAdmissionRule) ([]PolicyViolations, error) {
+	logger := log.FromContext(ctx)
+
+	nsl := &corev1.NamespaceList{}
+	if err := c.List(ctx, nsl); err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string]*corev1.Namespace, len(nsl.Items))
+	for i := range nsl.Items {
+		namespaces[nsl.Items[i].Name] = &nsl.Items[i]
+	}
+	npael := &tenetv1beta2.NetworkPolicyAdmissionExceptionList{}
+	if err := c.List(ctx, npael); err != nil {
+		return nil, err
+	}
+
+	// share one evaluator so that the filters of the rules are indexed only once.
+	e := &Evaluator{}
+	now := v1.Now()
+	var pvs []PolicyViolations
+	for _, npl := range []*unstructured.UnstructuredList{cilium.CiliumNetworkPolicyList(), cilium.CiliumClusterwideNetworkPolicyList()} {
+		if err := c.List(ctx, npl); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		for i := range npl.Items {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			np := &npl.Items[i]
+			vs, err := e.Evaluate(rules, namespaces[np.GetNamespace()], np)
+			if err != nil {
+				logger.Error(err, "failed to evaluate NetworkPolicy", "namespace", np.GetNamespace(), "name", np.GetName(), "kind", np.GetKind())
+				continue
+			}
+			vs, _ = Exempt(npael.Items, np.GetNamespace(), vs, now)
+			if len(vs) == 0 {
+				continue
+			}
+			pvs = append(pvs, PolicyViolations{Policy: np, Violations: vs})
+		}
+	}
+	return pvs, nil
+}
@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/iptrie"
+)
+
+// ExemptedViolation is a violation allowed by a NetworkPolicyAdmissionException.
+type ExemptedViolation struct {
+	Violation
+	// Exception is the exception allowing the violation.
+	Exception *tenetv1beta2.NetworkPolicyAdmissionException
+}
+
+// String returns a human-readable description of the exempted violation.
+func (v ExemptedViolation) String() string {
+	return fmt.Sprintf("%s is allowed by NetworkPolicyAdmissionException %s until %s",
+		v.Violation, v.Exception.Name, v.Exception.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// Exempt removes the violations allowed by exceptions active at now for the namespace.
+// It returns the remaining violations, and the exempted ones.
+// Exceptions never apply to CiliumClusterwideNetworkPolicies, whose namespace is empty.
+func Exempt(exceptions []tenetv1beta2.NetworkPolicyAdmissionException, namespace string, violations []Violation, now v1.Time) ([]Violation, []ExemptedViolation) {
+	if namespace == "" || len(violations) == 0 {
+		return violations, nil
+	}
+
+	var remaining []Violation
+	var exempted []ExemptedViolation
+	for _, violation := range violations {
+		npae := findException(exceptions, namespace, violation, now)
+		if npae == nil {
+			remaining = append(remaining, violation)
+			continue
+		}
+		exempted = append(exempted, ExemptedViolation{Violation: violation, Exception: npae})
+	}
+	return remaining, exempted
+}
+
+// findException returns an active exception allowing the violation in the namespace, or nil.
+func findException(exceptions []tenetv1beta2.NetworkPolicyAdmissionException, namespace string, violation Violation, now v1.Time) *tenetv1beta2.NetworkPolicyAdmissionException {
+	for i := range exceptions {
+		npae := &exceptions[i]
+		if npae.Spec.Namespace != namespace || npae.Spec.Rule != violation.Rule.Name || !npae.IsActive(now) {
+			continue
+		}
+		if exceptionCovers(npae, violation) {
+			return npae
+		}
+	}
+	return nil
+}
+
+// exceptionCovers reports whether the exception allows the offending peer of the violation.
+func exceptionCovers(npae *tenetv1beta2.NetworkPolicyAdmissionException, violation Violation) bool {
+	switch violation.Kind {
+	case KindIPRange:
+		if npae.Spec.CIDR == "" {
+			return false
+		}
+		// The API server rejects malformed CIDRs, so only exceptions stored before
+		// the validation was introduced can fail to parse. They never cover any peer.
+		allowed, err := iptrie.ParsePrefix(npae.Spec.CIDR)
+		if err != nil {
+			return false
+		}
+		peer, err := iptrie.ParsePrefix(violation.Value)
+		if err != nil {
+			return false
+		}
+		return iptrie.Contains(allowed, peer)
+	case KindEntity:
+		return npae.Spec.Entity != "" && cilium.EntityContains(npae.Spec.Entity, violation.Value)
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package policy
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/iptrie"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// filterIndex holds the pre-parsed filters of a set of NetworkPolicyAdmissionRules.
+// Filters refer to their rule by its position among the rules ordered by name, so that
+// an index built from one list of rules can be used with later lists of the same rules.
+// Forbidden IP ranges of allow sections and protected IP ranges of deny sections are indexed
+// in a prefix trie per policy section so that overlapping ranges can be found without
+// comparing against every filter, and namespace
+// selectors are parsed once so that the applicable rules are determined in one pass per request.
+type filterIndex struct {
+	// key identifies the generations of the rules the index was built from.
+	key string
+
+	selectors []*namespaceSelector
+
+	forbiddenIPs    map[string]*iptrie.Trie[ipFilter]
+	allowedIPs      map[string][]ipFilter
+	requiredDenyIPs map[string]*iptrie.Trie[ipFilter]
+	entities        map[string][]entityFilter
+}
+
+// filterCache keeps the filter index of the latest set of rules and rebuilds it when the rules change.
+type filterCache struct {
+	mu    sync.Mutex
+	index *filterIndex
+}
+
+// get returns the filter index for the rules, building it if the rules changed since the last call,
+// along with pointers to the given rules in the order the filters refer to them.
+func (c *filterCache) get(items []tenetv1beta3.NetworkPolicyAdmissionRule) (*filterIndex, []*tenetv1beta3.NetworkPolicyAdmissionRule, error) {
+	rules := sortedRules(items)
+	key := filterIndexKey(rules)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.index != nil && c.index.key == key {
+		return c.index, rules, nil
+	}
+	index, err := buildFilterIndex(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+	index.key = key
+	c.index = index
+	return index, rules, nil
+}
+
+// filterIndexKey returns a string identifying the rules and their generations.
+func filterIndexKey(rules []*tenetv1beta3.NetworkPolicyAdmissionRule) string {
+	var sb strings.Builder
+	for _, npar := range rules {
+		fmt.Fprintf(&sb, "%s/%s/%d;", npar.Name, npar.UID, npar.Generation)
+	}
+	return sb.String()
+}
+
+func buildFilterIndex(rules []*tenetv1beta3.NetworkPolicyAdmissionRule) (*filterIndex, error) {
+	index := &filterIndex{
+		forbiddenIPs:    make(map[string]*iptrie.Trie[ipFilter]),
+		allowedIPs:      make(map[string][]ipFilter),
+		requiredDenyIPs: make(map[string]*iptrie.Trie[ipFilter]),
+		entities:        make(map[string][]entityFilter),
+	}
+	order := 0
+	for rule, npar := range rules {
+		sel, err := newNamespaceSelector(npar.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("NetworkPolicyAdmissionRule %s: invalid namespace selector: %w", npar.Name, err)
+		}
+		index.selectors = append(index.selectors, sel)
+
+		for _, ipRange := range npar.Spec.ForbiddenIPRanges {
+			cidr, err := iptrie.ParsePrefix(ipRange.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			filter := ipFilter{
+				cidr:  cidr,
+				rule:  rule,
+				order: order,
+			}
+			order++
+			for _, ruleType := range filteredRuleTypes(ipRange.Type, false) {
+				insertIPFilter(index.forbiddenIPs, ruleType.Type, filter)
+			}
+		}
+
+		for _, ipRange := range npar.Spec.ProtectedIPRanges {
+			cidr, err := iptrie.ParsePrefix(ipRange.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			filter := ipFilter{
+				cidr:      cidr,
+				protected: true,
+				rule:      rule,
+				order:     order,
+			}
+			order++
+			for _, ruleType := range filteredRuleTypes(ipRange.Type, true) {
+				insertIPFilter(index.forbiddenIPs, ruleType.Type, filter)
+			}
+		}
+
+		for _, ipRange := range npar.Spec.RequiredDenyIPRanges {
+			cidr, err := iptrie.ParsePrefix(ipRange.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			filter := ipFilter{
+				cidr:         cidr,
+				requiredDeny: true,
+				rule:         rule,
+				order:        order,
+			}
+			order++
+			for _, ruleType := range filteredRuleTypes(ipRange.Type, false) {
+				insertIPFilter(index.requiredDenyIPs, ruleType.Type, filter)
+			}
+		}
+
+		allowed := make(map[string][]netip.Prefix)
+		for _, ipRange := range npar.Spec.AllowedIPRanges {
+			cidr, err := iptrie.ParsePrefix(ipRange.CIDR)
+			if err != nil {
+				return nil, err
+			}
+			for _, ruleType := range filteredRuleTypes(ipRange.Type, false) {
+				allowed[ruleType.Type] = append(allowed[ruleType.Type], cidr)
+			}
+		}
+		for _, ruleType := range cilium.RuleTypes {
+			if cidrs, ok := allowed[ruleType.Type]; ok {
+				index.allowedIPs[ruleType.Type] = append(index.allowedIPs[ruleType.Type], ipFilter{
+					allowed: cidrs,
+					rule:    rule,
+					order:   order,
+				})
+			}
+		}
+		order++
+
+		for _, entity := range npar.Spec.ForbiddenEntities {
+			filter := entityFilter{
+				entity: entity.Entity,
+				match:  entity.Match,
+				rule:   rule,
+			}
+			for _, ruleType := range filteredRuleTypes(entity.Type, false) {
+				index.entities[ruleType.Type] = append(index.entities[ruleType.Type], filter)
+			}
+		}
+
+		for _, entity := range npar.Spec.ProtectedEntities {
+			filter := entityFilter{
+				entity:    entity.Entity,
+				match:     entity.Match,
+				protected: true,
+				rule:      rule,
+			}
+			for _, ruleType := range filteredRuleTypes(entity.Type, true) {
+				index.entities[ruleType.Type] = append(index.entities[ruleType.Type], filter)
+			}
+		}
+	}
+	return index, nil
+}
+
+// sortedRules returns the rules ordered by name so that evaluation does not depend on the list order.
+// Rules of the same name, such as the old and new versions of a rule, keep their relative order.
+func sortedRules(items []tenetv1beta3.NetworkPolicyAdmissionRule) []*tenetv1beta3.NetworkPolicyAdmissionRule {
+	rules := make([]*tenetv1beta3.NetworkPolicyAdmissionRule, len(items))
+	for i := range items {
+		rules[i] = &items[i]
+	}
+	slices.SortStableFunc(rules, func(a, b *tenetv1beta3.NetworkPolicyAdmissionRule) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return rules
+}
+
+// insertIPFilter adds the filter to the trie of the section, creating the trie if needed.
+func insertIPFilter(tries map[string]*iptrie.Trie[ipFilter], section string, filter ipFilter) {
+	trie, ok := tries[section]
+	if !ok {
+		trie = &iptrie.Trie[ipFilter]{}
+		tries[section] = trie
+	}
+	trie.Insert(filter.cidr, filter)
+}
+
+// filteredRuleTypes returns the policy sections a definition of the given type applies to.
+// Protected definitions apply to deny sections, and all other definitions to allow sections.
+func filteredRuleTypes(t tenetv1beta3.NetworkPolicyAdmissionRuleType, deny bool) []cilium.RuleType {
+	var ruleTypes []cilium.RuleType
+	for _, ruleType := range cilium.RuleTypes {
+		if ruleType.Deny != deny {
+			continue
+		}
+		if t != tenetv1beta3.NetworkPolicyAdmissionRuleTypeAll && string(t) != ruleType.Direction {
+			continue
+		}
+		ruleTypes = append(ruleTypes, ruleType)
+	}
+	return ruleTypes
+}
+
+// ipFilter is a forbidden, protected or required-deny IP range, or a set of allowed IP ranges,
+// along with the position of the rule defining it.
+// order is the position of the filter among all filters of the rules.
+type ipFilter struct {
+	cidr         netip.Prefix
+	allowed      []netip.Prefix
+	protected    bool
+	requiredDeny bool
+	rule         int
+	order        int
+}
+
+// forbids reports whether the requested CIDR is denied by the filter.
+// denied lists the CIDRs denied by the deny section of the same direction in the policy rule
+// of the requested CIDR, which must cover the overlap with a required-deny range.
+func (f ipFilter) forbids(cidr netip.Prefix, denied []netip.Prefix) bool {
+	if f.requiredDeny {
+		if !iptrie.Overlaps(cidr, f.cidr) {
+			return false
+		}
+		overlap := f.cidr
+		if cidr.Bits() > overlap.Bits() {
+			overlap = cidr
+		}
+		return !iptrie.Covered(overlap, denied)
+	}
+	if f.allowed != nil {
+		return !iptrie.Covered(cidr, f.allowed)
+	}
+	return iptrie.Overlaps(cidr, f.cidr)
+}
+
+// allowedRanges returns the allowed IP ranges of the filter as strings.
+func (f ipFilter) allowedRanges() []string {
+	ranges := make([]string, 0, len(f.allowed))
+	for _, cidr := range f.allowed {
+		ranges = append(ranges, cidr.String())
+	}
+	return ranges
+}
+
+// entityFilter is a forbidden or protected entity along with how it should be matched
+// and the position of the rule defining it.
+type entityFilter struct {
+	entity    string
+	match     tenetv1beta3.NetworkPolicyAdmissionRuleEntityMatch
+	protected bool
+	rule      int
+}
+
+// forbids reports whether the requested entity is denied by the filter.
+func (f entityFilter) forbids(entity string) bool {
+	if f.match == tenetv1beta3.NetworkPolicyAdmissionRuleEntityMatchExact {
+		return f.entity == entity
+	}
+	return cilium.EntitiesOverlap(f.entity, entity)
+}
+
+// applicableRules returns, for each position of the filters' rules, the rule if it applies to
+// network policies in the namespace, or nil otherwise. rules are the rules in the order returned by filterCache.get.
+// ns is nil for clusterwide network policies.
+func (i *filterIndex) applicableRules(rules []*tenetv1beta3.NetworkPolicyAdmissionRule, ns *corev1.Namespace) []*tenetv1beta3.NetworkPolicyAdmissionRule {
+	applicable := make([]*tenetv1beta3.NetworkPolicyAdmissionRule, len(rules))
+	for j, npar := range rules {
+		if i.selectors[j].matches(ns) {
+			applicable[j] = npar
+		}
+	}
+	return applicable
+}
+
+// ipFilters returns the filters of the section that may forbid the CIDR, in rule order.
+func (i *filterIndex) ipFilters(section string, cidr netip.Prefix) []ipFilter {
+	var filters []ipFilter
+	if trie, ok := i.forbiddenIPs[section]; ok {
+		filters = trie.Overlapping(cidr)
+	}
+	if trie, ok := i.requiredDenyIPs[section]; ok {
+		filters = append(filters, trie.Overlapping(cidr)...)
+	}
+	filters = append(filters, i.allowedIPs[section]...)
+	slices.SortFunc(filters, func(a, b ipFilter) int {
+		return cmp.Compare(a.order, b.order)
+	})
+	return filters
+}
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+// ValidateNamespaceSelector returns an error if the label selectors of the namespace selector cannot be parsed.
+func ValidateNamespaceSelector(sel tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector) error {
+	_, err := newNamespaceSelector(sel)
+	return err
+}
+
+// namespaceSelector is the parsed form of a NetworkPolicyAdmissionRuleNamespaceSelector.
+type namespaceSelector struct {
+	namespaces        []string
+	excludeNamespaces []string
+	// include and exclude are nil when no labels nor expressions are set.
+	include labels.Selector
+	exclude labels.Selector
+}
+
+func newNamespaceSelector(sel tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector) (*namespaceSelector, error) {
+	s := &namespaceSelector{
+		namespaces:        sel.Namespaces,
+		excludeNamespaces: sel.ExcludeNamespaces,
+	}
+	if len(sel.MatchLabels)+len(sel.MatchExpressions) > 0 {
+		include, err := v1.LabelSelectorAsSelector(&v1.LabelSelector{
+			MatchLabels:      sel.MatchLabels,
+			MatchExpressions: sel.MatchExpressions,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("matchLabels or matchExpressions: %w", err)
+		}
+		s.include = include
+	}
+	if len(sel.ExcludeLabels)+len(sel.ExcludeLabelExpressions) > 0 {
+		exclude, err := v1.LabelSelectorAsSelector(&v1.LabelSelector{
+			MatchLabels:      sel.ExcludeLabels,
+			MatchExpressions: sel.ExcludeLabelExpressions,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("excludeLabels or excludeLabelExpressions: %w", err)
+		}
+		s.exclude = exclude
+	}
+	return s, nil
+}
+
+// matches reports whether the namespace is selected.
+// ns is nil for clusterwide network policies, which only match selectors without inclusion criteria.
+func (s *namespaceSelector) matches(ns *corev1.Namespace) bool {
+	var name string
+	var ls labels.Set
+	if ns != nil {
+		name = ns.Name
+		ls = ns.Labels
+	}
+
+	if len(s.namespaces) > 0 && !slices.Contains(s.namespaces, name) {
+		return false
+	}
+	if slices.Contains(s.excludeNamespaces, name) {
+		return false
+	}
+	if s.include != nil && !s.include.Matches(ls) {
+		return false
+	}
+	if s.exclude != nil && s.exclude.Matches(ls) {
+		return false
+	}
+	return true
+}
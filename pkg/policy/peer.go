@@ -0,0 +1,134 @@
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/iptrie"
+)
+
+// policyPeer is a CIDR, entity or DNS name referred to by a network policy.
+// kind is one of the kinds of offending peers, e.g. KindEntity.
+// rulePath is the JSON path of the policy rule containing the peer, e.g. specs[1], and
+// entry numbers the ingress and egress rules of the policy in the order of gatherPeers.
+type policyPeer struct {
+	ruleType cilium.RuleType
+	kind     string
+	value    string
+	rulePath string
+	path     string
+	entry    int
+}
+
+// ipPolicyPeer is a CIDR referred to by a network policy.
+// except lists the ranges excluded from the CIDR by a CIDRSet entry.
+type ipPolicyPeer struct {
+	policyPeer
+	cidr   netip.Prefix
+	except []netip.Prefix
+}
+
+// gatherIPPeers collects the CIDRs referred to by the policy.
+// CIDRSet entries referring to a CiliumCIDRGroup instead of a CIDR are skipped.
+func gatherIPPeers(p *cilium.Policy) ([]ipPolicyPeer, error) {
+	var policies []ipPolicyPeer
+	entry := 0
+	add := func(ruleType cilium.RuleType, value, rulePath, path string, excepts []string) error {
+		if value == "" {
+			return nil
+		}
+		cidr, err := iptrie.ParsePrefix(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var except []netip.Prefix
+		for i, value := range excepts {
+			p, err := iptrie.ParsePrefix(value)
+			if err != nil {
+				return fmt.Errorf("%s.except[%d]: %w", path, i, err)
+			}
+			except = append(except, p)
+		}
+		policies = append(policies, ipPolicyPeer{
+			policyPeer: policyPeer{ruleType: ruleType, kind: KindIPRange, value: value, rulePath: rulePath, path: path, entry: entry},
+			cidr:       cidr,
+			except:     except,
+		})
+		return nil
+	}
+	err := gatherPeers(p, func(ruleType cilium.RuleType, peers cilium.Peers, rulePath, path string) error {
+		for i, cidr := range peers.CIDR {
+			if err := add(ruleType, cidr, rulePath, fmt.Sprintf("%s.%s[%d]", path, ruleType.RuleKeys[cilium.CIDRRuleKey], i), nil); err != nil {
+				return err
+			}
+		}
+		for i, set := range peers.CIDRSet {
+			path := fmt.Sprintf("%s.%s[%d]", path, ruleType.RuleKeys[cilium.CIDRSetRuleKey], i)
+			if err := add(ruleType, set.CIDR, rulePath, path, set.Except); err != nil {
+				return err
+			}
+		}
+		entry++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// gatherNamedPeers collects the entities and DNS names referred to by the policy,
+// entities coming before DNS names within an ingress or egress rule.
+func gatherNamedPeers(p *cilium.Policy) []policyPeer {
+	var policies []policyPeer
+	entry := 0
+	_ = gatherPeers(p, func(ruleType cilium.RuleType, peers cilium.Peers, rulePath, path string) error {
+		for i, entity := range peers.Entities {
+			if entity == "" {
+				continue
+			}
+			policies = append(policies, policyPeer{
+				ruleType: ruleType,
+				kind:     KindEntity,
+				value:    entity,
+				rulePath: rulePath,
+				path:     fmt.Sprintf("%s.%s[%d]", path, ruleType.RuleKeys[cilium.EntityRuleKey], i),
+				entry:    entry,
+			})
+		}
+		for i, fqdn := range peers.FQDNs {
+			if fqdn.String() == "" {
+				continue
+			}
+			policies = append(policies, policyPeer{
+				ruleType: ruleType,
+				kind:     KindFQDN,
+				value:    fqdn.String(),
+				rulePath: rulePath,
+				path:     fmt.Sprintf("%s.%s[%d]", path, ruleType.RuleKeys[cilium.FQDNRuleKey], i),
+				entry:    entry,
+			})
+		}
+		entry++
+		return nil
+	})
+	return policies
+}
+
+// gatherPeers calls fn with the peers of every ingress and egress rule of the policy,
+// ordered by policy rule, then by section in the order of cilium.RuleTypes, then by position in the section.
+// rulePath is the JSON path of the policy rule, e.g. specs[1], and path is the JSON path
+// of the ingress or egress rule, e.g. specs[1].egress[0].
+func gatherPeers(p *cilium.Policy, fn func(ruleType cilium.RuleType, peers cilium.Peers, rulePath, path string) error) error {
+	for _, rule := range p.Rules() {
+		for _, ruleType := range cilium.RuleTypes {
+			for i, peers := range rule.Section(ruleType) {
+				if err := fn(ruleType, peers, rule.Path, fmt.Sprintf("%s.%s[%d]", rule.Path, ruleType.Type, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
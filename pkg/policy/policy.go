@@ -0,0 +1,225 @@
+// Package policy evaluates CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies against NetworkPolicyAdmissionRules.
+// It is shared by the admission webhook, the audit controller and tenetctl.
+package policy
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/iptrie"
+)
+
+// Violation is a violation of a NetworkPolicyAdmissionRule found in a network policy.
+type Violation struct {
+	// Rule is the violated rule. It points to an element of the rules given to Evaluate.
+	Rule *tenetv1beta3.NetworkPolicyAdmissionRule
+	// Section is the policy section containing the offending peer, e.g. egress or ingressDeny.
+	Section string
+	// Kind is the kind of the offending peer, either "IP range", "entity" or "FQDN".
+	Kind string
+	// Value is the offending peer.
+	Value string
+	// Forbidden is the forbidden value of the rule matched by the peer.
+	Forbidden string
+	// Allowed lists the allowed IP ranges of the rule if the peer is outside of them.
+	Allowed []string
+	// Protected is the protected value of the rule denied by the peer in a deny section.
+	Protected string
+	// RequiredDeny is the required-deny IP range of the rule allowed by the peer
+	// without being denied by the deny section of the same policy rule.
+	RequiredDeny string
+	// Path is the JSON path of the offending peer in the policy, e.g. specs[1].egress[0].toCIDRSet[2].
+	Path string
+}
+
+// Kinds of offending peers.
+const (
+	KindIPRange = "IP range"
+	KindEntity  = "entity"
+	KindFQDN    = "FQDN"
+)
+
+// String returns a human-readable description of the violation.
+func (v Violation) String() string {
+	switch {
+	case len(v.Allowed) > 0:
+		return fmt.Sprintf("NetworkPolicyAdmissionRule %s: %s %s %s at %s is outside allowed %s",
+			v.Rule.Name, v.Section, v.Kind, v.Value, v.Path, strings.Join(v.Allowed, ", "))
+	case v.Protected != "":
+		return fmt.Sprintf("NetworkPolicyAdmissionRule %s: %s %s %s at %s denies protected %s",
+			v.Rule.Name, v.Section, v.Kind, v.Value, v.Path, v.Protected)
+	case v.RequiredDeny != "":
+		return fmt.Sprintf("NetworkPolicyAdmissionRule %s: %s %s %s at %s overlaps %s, which must be denied by %sDeny in the same rule",
+			v.Rule.Name, v.Section, v.Kind, v.Value, v.Path, v.RequiredDeny, v.Section)
+	}
+	return fmt.Sprintf("NetworkPolicyAdmissionRule %s: %s %s %s at %s overlaps forbidden %s",
+		v.Rule.Name, v.Section, v.Kind, v.Value, v.Path, v.Forbidden)
+}
+
+// Denies reports whether the admission webhook rejects a policy with the violation,
+// i.e. the enforcement action of the rule is neither warn nor dryrun.
+func (v Violation) Denies() bool {
+	switch v.Rule.Spec.EnforcementAction {
+	case tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionWarn, tenetv1beta3.NetworkPolicyAdmissionRuleEnforcementActionDryRun:
+		return false
+	default:
+		return true
+	}
+}
+
+// Evaluate returns the violations of the rules found in a CiliumNetworkPolicy or
+// CiliumClusterwideNetworkPolicy, given the namespace the policy belongs to.
+// ns is nil for CiliumClusterwideNetworkPolicies.
+// Violations are ordered by the position of the offending peer in the policy, then by rule name.
+// Peers are ordered by policy rule, then by section in the order egress, ingress, egressDeny
+// and ingressDeny, then by position in the section, CIDRs and CIDRSets coming before entities,
+// and entities before DNS names, within an ingress or egress rule.
+// Use an Evaluator to evaluate many policies against the same rules.
+func Evaluate(rules []tenetv1beta3.NetworkPolicyAdmissionRule, ns *corev1.Namespace, np *unstructured.Unstructured) ([]Violation, error) {
+	return (&Evaluator{}).Evaluate(rules, ns, np)
+}
+
+// Evaluator evaluates network policies against NetworkPolicyAdmissionRules.
+// It keeps the parsed filters of the latest rules so that they are not parsed again
+// while the rules do not change. The zero value is ready to use, and an Evaluator
+// is safe for concurrent use.
+type Evaluator struct {
+	filters filterCache
+}
+
+// Evaluate is like the package-level Evaluate, reusing the parsed filters of the rules if they did not change.
+func (e *Evaluator) Evaluate(rules []tenetv1beta3.NetworkPolicyAdmissionRule, ns *corev1.Namespace, np *unstructured.Unstructured) ([]Violation, error) {
+	index, sorted, err := e.filters.get(rules)
+	if err != nil {
+		return nil, err
+	}
+	p, err := cilium.DecodePolicy(np)
+	if err != nil {
+		return nil, err
+	}
+	ipPeers, err := gatherIPPeers(p)
+	if err != nil {
+		return nil, err
+	}
+	namedPeers := gatherNamedPeers(p)
+	applicable := index.applicableRules(sorted, ns)
+	denied := deniedIPs(ipPeers)
+
+	// Merge the violations of CIDRs and named peers, CIDRs first within an ingress or egress rule.
+	var violations []Violation
+	for len(ipPeers) > 0 || len(namedPeers) > 0 {
+		if len(namedPeers) == 0 || (len(ipPeers) > 0 && ipPeers[0].entry <= namedPeers[0].entry) {
+			violations = append(violations, evaluateIP(index, applicable, ipPeers[0], denied)...)
+			ipPeers = ipPeers[1:]
+			continue
+		}
+		if namedPeers[0].kind == KindFQDN {
+			// The addresses of a DNS name are unknown until it is resolved, so they may be anywhere.
+			violations = append(violations, evaluateAllowed(index, applicable, namedPeers[0])...)
+		} else {
+			violations = append(violations, evaluateEntity(index, applicable, namedPeers[0])...)
+		}
+		namedPeers = namedPeers[1:]
+	}
+	return violations, nil
+}
+
+// deniedIPs maps policy rules and directions to the CIDRs denied by their deny sections.
+// The exceptions of CIDRSet entries are not denied, so they are subtracted from the CIDR.
+func deniedIPs(peers []ipPolicyPeer) map[string][]netip.Prefix {
+	denied := make(map[string][]netip.Prefix)
+	for _, peer := range peers {
+		if peer.ruleType.Deny {
+			key := deniedIPsKey(peer)
+			denied[key] = append(denied[key], iptrie.Subtract(peer.cidr, peer.except)...)
+		}
+	}
+	return denied
+}
+
+func deniedIPsKey(peer ipPolicyPeer) string {
+	return peer.rulePath + "/" + peer.ruleType.Direction
+}
+
+func evaluateIP(index *filterIndex, applicable []*tenetv1beta3.NetworkPolicyAdmissionRule, peer ipPolicyPeer, denied map[string][]netip.Prefix) []Violation {
+	var violations []Violation
+	for _, filter := range index.ipFilters(peer.ruleType.Type, peer.cidr) {
+		rule := applicable[filter.rule]
+		if rule == nil || !filter.forbids(peer.cidr, denied[deniedIPsKey(peer)]) {
+			continue
+		}
+		violation := Violation{
+			Rule:    rule,
+			Section: peer.ruleType.Type,
+			Kind:    KindIPRange,
+			Value:   peer.value,
+			Path:    peer.path,
+		}
+		switch {
+		case filter.allowed != nil:
+			violation.Allowed = filter.allowedRanges()
+		case filter.protected:
+			violation.Protected = filter.cidr.String()
+		case filter.requiredDeny:
+			violation.RequiredDeny = filter.cidr.String()
+		default:
+			violation.Forbidden = filter.cidr.String()
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+func evaluateEntity(index *filterIndex, applicable []*tenetv1beta3.NetworkPolicyAdmissionRule, peer policyPeer) []Violation {
+	var violations []Violation
+	for _, filter := range index.entities[peer.ruleType.Type] {
+		rule := applicable[filter.rule]
+		if rule == nil || !filter.forbids(peer.value) {
+			continue
+		}
+		violation := Violation{
+			Rule:    rule,
+			Section: peer.ruleType.Type,
+			Kind:    KindEntity,
+			Value:   peer.value,
+			Path:    peer.path,
+		}
+		if filter.protected {
+			violation.Protected = filter.entity
+		} else {
+			violation.Forbidden = filter.entity
+		}
+		violations = append(violations, violation)
+	}
+	// Entities overlapping world may refer to any address outside of the cluster.
+	if cilium.EntitiesOverlap(cilium.EntityWorld, peer.value) {
+		violations = append(violations, evaluateAllowed(index, applicable, peer)...)
+	}
+	return violations
+}
+
+// evaluateAllowed returns a violation of every applicable rule with allowed IP ranges for the section of the peer.
+func evaluateAllowed(index *filterIndex, applicable []*tenetv1beta3.NetworkPolicyAdmissionRule, peer policyPeer) []Violation {
+	var violations []Violation
+	for _, filter := range index.allowedIPs[peer.ruleType.Type] {
+		rule := applicable[filter.rule]
+		if rule == nil {
+			continue
+		}
+		violations = append(violations, Violation{
+			Rule:    rule,
+			Section: peer.ruleType.Type,
+			Kind:    peer.kind,
+			Value:   peer.value,
+			Allowed: filter.allowedRanges(),
+			Path:    peer.path,
+		})
+	}
+	return violations
+}
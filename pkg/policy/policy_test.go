@@ -0,0 +1,498 @@
+package policy
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	tenetv1beta2 "github.com/cybozu-go/tenet/api/v1beta2"
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+)
+
+var testRules = []tenetv1beta3.NetworkPolicyAdmissionRule{
+	{
+		ObjectMeta: v1.ObjectMeta{Name: "forbid-bmc"},
+		Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+			NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+				ExcludeLabels: map[string]string{"team": "neco"},
+			},
+			ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+				{CIDR: "10.72.16.0/20", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeEgress},
+			},
+			ForbiddenEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenEntity{
+				{Entity: "host", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeAll},
+			},
+		},
+	},
+	{
+		ObjectMeta: v1.ObjectMeta{Name: "allow-internal"},
+		Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+			NamespaceSelector: tenetv1beta3.NetworkPolicyAdmissionRuleNamespaceSelector{
+				Namespaces: []string{"restricted"},
+			},
+			AllowedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleAllowedIPRanges{
+				{CIDR: "10.0.0.0/8", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeEgress},
+			},
+		},
+	},
+}
+
+func decodePolicy(t testing.TB, manifest string) *unstructured.Unstructured {
+	np := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(manifest), &np.Object); err != nil {
+		t.Fatal(err)
+	}
+	return np
+}
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestEvaluate(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+specs:
+- endpointSelector: {}
+  egress:
+  - toCIDR:
+    - 192.168.0.0/24
+    - 10.72.16.0/24
+  - toEntities:
+    - cluster
+- endpointSelector: {}
+  ingress:
+  - fromCIDRSet:
+    - cidr: 10.72.16.0/24
+`)
+
+	cases := []struct {
+		name string
+		ns   *corev1.Namespace
+		want []string
+	}{
+		{
+			name: "tenant namespace",
+			ns:   namespace("tenant", nil),
+			want: []string{
+				"NetworkPolicyAdmissionRule forbid-bmc: egress IP range 10.72.16.0/24 at specs[0].egress[0].toCIDR[1] overlaps forbidden 10.72.16.0/20",
+				"NetworkPolicyAdmissionRule forbid-bmc: egress entity cluster at specs[0].egress[1].toEntities[0] overlaps forbidden host",
+			},
+		},
+		{
+			name: "excluded namespace",
+			ns:   namespace("neco", map[string]string{"team": "neco"}),
+		},
+		{
+			name: "restricted namespace",
+			ns:   namespace("restricted", nil),
+			want: []string{
+				"NetworkPolicyAdmissionRule allow-internal: egress IP range 192.168.0.0/24 at specs[0].egress[0].toCIDR[0] is outside allowed 10.0.0.0/8",
+				"NetworkPolicyAdmissionRule forbid-bmc: egress IP range 10.72.16.0/24 at specs[0].egress[0].toCIDR[1] overlaps forbidden 10.72.16.0/20",
+				"NetworkPolicyAdmissionRule forbid-bmc: egress entity cluster at specs[0].egress[1].toEntities[0] overlaps forbidden host",
+			},
+		},
+	}
+	var e Evaluator
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			vs, err := e.Evaluate(testRules, tc.ns, np)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range vs {
+				got = append(got, v.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatorRules(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  egress:
+  - toCIDR:
+    - 10.72.16.0/24
+`)
+	var e Evaluator
+	for range 2 {
+		// the second call reuses the filters of the first one with another copy of the rules.
+		rules := slices.Clone(testRules)
+		vs, err := e.Evaluate(rules, namespace("tenant", nil), np)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vs) != 1 || vs[0].Rule != &rules[0] {
+			t.Fatalf("violations should refer to the given rules, got %v", vs)
+		}
+	}
+}
+
+func TestEvaluateAllowedNamedPeers(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  egress:
+  - toEntities:
+    - world-ipv4
+    - cluster
+    toFQDNs:
+    - matchName: example.com
+    - matchPattern: "*.example.org"
+  ingress:
+  - fromEntities:
+    - world
+`)
+	vs, err := Evaluate(testRules, namespace("restricted", nil), np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.String())
+	}
+	want := []string{
+		"NetworkPolicyAdmissionRule allow-internal: egress entity world-ipv4 at spec.egress[0].toEntities[0] is outside allowed 10.0.0.0/8",
+		"NetworkPolicyAdmissionRule forbid-bmc: egress entity cluster at spec.egress[0].toEntities[1] overlaps forbidden host",
+		"NetworkPolicyAdmissionRule allow-internal: egress FQDN example.com at spec.egress[0].toFQDNs[0] is outside allowed 10.0.0.0/8",
+		"NetworkPolicyAdmissionRule allow-internal: egress FQDN *.example.org at spec.egress[0].toFQDNs[1] is outside allowed 10.0.0.0/8",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvaluateOrder(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+specs:
+- endpointSelector: {}
+  ingress:
+  - fromEntities:
+    - host
+  egress:
+  - toEntities:
+    - host
+  - toCIDR:
+    - 10.72.16.0/24
+- endpointSelector: {}
+  egress:
+  - toEntities:
+    - cluster
+    toCIDR:
+    - 10.72.17.0/24
+`)
+	vs, err := Evaluate(testRules, namespace("tenant", nil), np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range vs {
+		got = append(got, v.Path)
+	}
+	want := []string{
+		"specs[0].egress[0].toEntities[0]",
+		"specs[0].egress[1].toCIDR[0]",
+		"specs[0].ingress[0].fromEntities[0]",
+		"specs[1].egress[0].toCIDR[0]",
+		"specs[1].egress[0].toEntities[0]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEvaluateDenySections(t *testing.T) {
+	rules := []tenetv1beta3.NetworkPolicyAdmissionRule{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "deny-sections"},
+			Spec: tenetv1beta3.NetworkPolicyAdmissionRuleSpec{
+				ForbiddenIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleForbiddenIPRanges{
+					{CIDR: "172.16.0.0/12", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeAll},
+				},
+				ProtectedIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleProtectedIPRanges{
+					{CIDR: "10.64.0.0/24", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeEgress},
+				},
+				ProtectedEntities: []tenetv1beta3.NetworkPolicyAdmissionRuleProtectedEntity{
+					{Entity: "kube-apiserver", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeAll},
+				},
+				RequiredDenyIPRanges: []tenetv1beta3.NetworkPolicyAdmissionRuleRequiredDenyIPRanges{
+					{CIDR: "10.96.0.0/12", Type: tenetv1beta3.NetworkPolicyAdmissionRuleTypeEgress},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		policy string
+		want   []string
+	}{
+		{
+			name: "forbidden ranges in deny sections",
+			policy: `
+spec:
+  egressDeny:
+  - toCIDR:
+    - 172.16.0.0/16
+`,
+		},
+		{
+			name: "protected range and entity",
+			policy: `
+spec:
+  egressDeny:
+  - toCIDR:
+    - 10.0.0.0/8
+    - 10.65.0.0/24
+  - toEntities:
+    - cluster
+  ingressDeny:
+  - fromCIDR:
+    - 10.64.0.0/24
+`,
+			want: []string{
+				"NetworkPolicyAdmissionRule deny-sections: egressDeny IP range 10.0.0.0/8 at spec.egressDeny[0].toCIDR[0] denies protected 10.64.0.0/24",
+				"NetworkPolicyAdmissionRule deny-sections: egressDeny entity cluster at spec.egressDeny[1].toEntities[0] denies protected kube-apiserver",
+			},
+		},
+		{
+			name: "required deny missing",
+			policy: `
+spec:
+  egress:
+  - toCIDR:
+    - 10.100.0.0/16
+    - 192.168.0.0/16
+  ingress:
+  - fromCIDR:
+    - 10.96.0.0/12
+`,
+			want: []string{
+				"NetworkPolicyAdmissionRule deny-sections: egress IP range 10.100.0.0/16 at spec.egress[0].toCIDR[0] overlaps 10.96.0.0/12, which must be denied by egressDeny in the same rule",
+			},
+		},
+		{
+			name: "required deny covered by the same rule",
+			policy: `
+spec:
+  egress:
+  - toCIDR:
+    - 10.0.0.0/8
+  egressDeny:
+  - toCIDR:
+    - 10.96.0.0/13
+  - toCIDRSet:
+    - cidr: 10.104.0.0/13
+`,
+		},
+		{
+			name: "required deny partially covered",
+			policy: `
+spec:
+  egress:
+  - toCIDR:
+    - 10.0.0.0/8
+  egressDeny:
+  - toCIDR:
+    - 10.96.0.0/13
+`,
+			want: []string{
+				"NetworkPolicyAdmissionRule deny-sections: egress IP range 10.0.0.0/8 at spec.egress[0].toCIDR[0] overlaps 10.96.0.0/12, which must be denied by egressDeny in the same rule",
+			},
+		},
+		{
+			name: "required deny with exceptions",
+			policy: `
+spec:
+  egress:
+  - toCIDR:
+    - 10.100.0.0/16
+  egressDeny:
+  - toCIDRSet:
+    - cidr: 10.96.0.0/12
+      except:
+      - 10.100.1.0/24
+`,
+			want: []string{
+				"NetworkPolicyAdmissionRule deny-sections: egress IP range 10.100.0.0/16 at spec.egress[0].toCIDR[0] overlaps 10.96.0.0/12, which must be denied by egressDeny in the same rule",
+			},
+		},
+		{
+			name: "required deny with exceptions outside the allowed range",
+			policy: `
+spec:
+  egress:
+  - toCIDR:
+    - 10.100.0.0/16
+  egressDeny:
+  - toCIDRSet:
+    - cidr: 10.96.0.0/12
+      except:
+      - 10.101.0.0/16
+      - 10.104.0.0/13
+`,
+		},
+		{
+			name: "required deny in another rule",
+			policy: `
+specs:
+- egress:
+  - toCIDR:
+    - 10.100.0.0/16
+- egressDeny:
+  - toCIDR:
+    - 10.96.0.0/12
+`,
+			want: []string{
+				"NetworkPolicyAdmissionRule deny-sections: egress IP range 10.100.0.0/16 at specs[0].egress[0].toCIDR[0] overlaps 10.96.0.0/12, which must be denied by egressDeny in the same rule",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			np := decodePolicy(t, "apiVersion: cilium.io/v2\nkind: CiliumNetworkPolicy\nmetadata:\n  name: test\n"+tc.policy)
+			vs, err := Evaluate(rules, namespace("tenant", nil), np)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range vs {
+				got = append(got, v.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateInvalidPolicy(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  endpointSelector: {}
+  egress:
+  - toCIDR:
+    - not-a-cidr
+`)
+	if _, err := Evaluate(testRules, namespace("tenant", nil), np); err == nil {
+		t.Error("evaluating an invalid CIDR should fail")
+	}
+}
+
+func TestExempt(t *testing.T) {
+	np := decodePolicy(t, `
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: test
+spec:
+  endpointSelector: {}
+  egress:
+  - toCIDR:
+    - 10.72.16.0/24
+    - 10.72.17.0/24
+`)
+	vs, err := Evaluate(testRules, namespace("tenant", nil), np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 {
+		t.Fatalf("expected 2 violations, got %v", vs)
+	}
+
+	now := v1.Now()
+	exceptions := []tenetv1beta2.NetworkPolicyAdmissionException{
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "expired"},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: "tenant",
+				Rule:      "forbid-bmc",
+				CIDR:      "10.72.0.0/16",
+				ExpiresAt: v1.NewTime(now.Add(-time.Hour)),
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "other-namespace"},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: "other",
+				Rule:      "forbid-bmc",
+				CIDR:      "10.72.0.0/16",
+				ExpiresAt: v1.NewTime(now.Add(time.Hour)),
+			},
+		},
+		{
+			ObjectMeta: v1.ObjectMeta{Name: "active"},
+			Spec: tenetv1beta2.NetworkPolicyAdmissionExceptionSpec{
+				Namespace: "tenant",
+				Rule:      "forbid-bmc",
+				CIDR:      "10.72.16.0/24",
+				ExpiresAt: v1.NewTime(now.Add(time.Hour)),
+			},
+		},
+	}
+	remaining, exempted := Exempt(exceptions, "tenant", vs, now)
+	if len(remaining) != 1 || remaining[0].Value != "10.72.17.0/24" {
+		t.Errorf("unexpected remaining violations %v", remaining)
+	}
+	if len(exempted) != 1 || exempted[0].Value != "10.72.16.0/24" || exempted[0].Exception.Name != "active" {
+		t.Errorf("unexpected exempted violations %v", exempted)
+	}
+
+	if remaining, _ := Exempt(exceptions, "", vs, now); len(remaining) != 2 {
+		t.Errorf("exceptions should not apply to clusterwide policies, got %v", remaining)
+	}
+}
+
+func FuzzEvaluate(f *testing.F) {
+	f.Add(`{"apiVersion":"cilium.io/v2","kind":"CiliumNetworkPolicy","spec":{"egress":[{"toCIDR":["10.72.16.0/24"]}]}}`)
+	f.Add(`{"apiVersion":"cilium.io/v2","kind":"CiliumNetworkPolicy","specs":[{"ingress":[{"fromCIDRSet":[{"cidr":"::ffff:10.72.16.0/120"}]}]}]}`)
+	f.Add(`{"apiVersion":"cilium.io/v2","kind":"CiliumNetworkPolicy","spec":{"egressDeny":[{"toEntities":["world",null]}]}}`)
+	f.Fuzz(func(t *testing.T, manifest string) {
+		np := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifest), &np.Object); err != nil {
+			return
+		}
+		vs, err := Evaluate(testRules, namespace("restricted", nil), np)
+		if err != nil {
+			return
+		}
+		for _, v := range vs {
+			var kinds int
+			for _, set := range []bool{v.Forbidden != "", len(v.Allowed) > 0, v.Protected != "", v.RequiredDeny != ""} {
+				if set {
+					kinds++
+				}
+			}
+			if v.Rule == nil || v.Path == "" || kinds != 1 {
+				t.Errorf("malformed violation %#v", v)
+			}
+		}
+	})
+}
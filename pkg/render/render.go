@@ -0,0 +1,120 @@
+// Package render renders network policies from NetworkPolicyTemplates.
+// It is shared by the controller and tenetctl so that both produce the same policies.
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/tenet"
+)
+
+// IsOptedIn reports whether the namespace opts into the template.
+func IsOptedIn(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace) bool {
+	return slices.Contains(strings.Split(ns.Annotations[tenet.PolicyAnnotation], ","), npt.Name)
+}
+
+// PolicyKey returns the key of the network policy generated from the template for the namespace.
+func PolicyKey(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace) types.NamespacedName {
+	if npt.Spec.ClusterWide {
+		return types.NamespacedName{Name: fmt.Sprintf("%s-%s", ns.Name, npt.Name)}
+	}
+	return types.NamespacedName{Namespace: ns.Name, Name: npt.Name}
+}
+
+// Revision returns the revision of a policy template, a short hash of its source.
+func Revision(policyTemplate string) string {
+	sum := sha256.Sum256([]byte(policyTemplate))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// IsTemplateOwner reports whether the owner reference refers to a NetworkPolicyTemplate.
+// The API version is ignored because policies generated before v1beta3 refer to v1beta2 templates.
+func IsTemplateOwner(ref metav1.OwnerReference) bool {
+	return schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind() == tenetv1beta3.GroupVersion.WithKind(tenetv1beta3.NetworkPolicyTemplateKind).GroupKind()
+}
+
+// NewPolicy returns an empty CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy depending on the template.
+func NewPolicy(npt *tenetv1beta3.NetworkPolicyTemplate) *unstructured.Unstructured {
+	if npt.Spec.ClusterWide {
+		return cilium.CiliumClusterwideNetworkPolicy()
+	}
+	return cilium.CiliumNetworkPolicy()
+}
+
+// Parse parses the policy template of the NetworkPolicyTemplate.
+func Parse(npt *tenetv1beta3.NetworkPolicyTemplate) (*template.Template, error) {
+	return template.New(npt.Name).Parse(npt.Spec.PolicyTemplate)
+}
+
+// Execute executes the template with the metadata of the namespace and decodes the result as it is,
+// without checking its kind nor overriding its name.
+func Execute(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace) (*unstructured.Unstructured, error) {
+	tpl, err := Parse(npt)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, ns.ObjectMeta); err != nil {
+		return nil, err
+	}
+	np := &unstructured.Unstructured{}
+	y := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buf.Bytes()), buf.Len())
+	if err := y.Decode(np); err != nil {
+		return nil, err
+	}
+	return np, nil
+}
+
+// CheckKind checks that the network policy is of the kind generated from the template.
+func CheckKind(npt *tenetv1beta3.NetworkPolicyTemplate, np *unstructured.Unstructured) error {
+	refNP := NewPolicy(npt)
+	if np.GetAPIVersion() != refNP.GetAPIVersion() || np.GetKind() != refNP.GetKind() {
+		return fmt.Errorf("invalid schema: %v, expected %v for clusterwide=%t",
+			np.GetObjectKind().GroupVersionKind(), refNP.GetObjectKind().GroupVersionKind(), npt.Spec.ClusterWide)
+	}
+	return nil
+}
+
+// Render executes the template with the metadata of the namespace and returns the resulting network policy,
+// named after PolicyKey, annotated with the revision of the template and owned by the template.
+// scheme must know NetworkPolicyTemplate.
+func Render(npt *tenetv1beta3.NetworkPolicyTemplate, ns *corev1.Namespace, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	np, err := Execute(npt, ns)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckKind(npt, np); err != nil {
+		return nil, err
+	}
+
+	key := PolicyKey(npt, ns)
+	np.SetNamespace(key.Namespace)
+	np.SetName(key.Name)
+	annotations := np.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[tenet.RevisionAnnotation] = Revision(npt.Spec.PolicyTemplate)
+	np.SetAnnotations(annotations)
+	if err := controllerutil.SetOwnerReference(npt, np, scheme); err != nil {
+		return nil, err
+	}
+	return np, nil
+}
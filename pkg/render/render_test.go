@@ -0,0 +1,95 @@
+package render
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	tenetv1beta3 "github.com/cybozu-go/tenet/api/v1beta3"
+	"github.com/cybozu-go/tenet/pkg/cilium"
+	"github.com/cybozu-go/tenet/pkg/tenet"
+)
+
+const intraNSTemplate = `apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+spec:
+  endpointSelector: {}
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: {{.Name}}
+`
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := tenetv1beta3.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestRender(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Annotations: map[string]string{
+				tenet.PolicyAnnotation: "allow-intra-namespace,other",
+			},
+		},
+	}
+	npt := &tenetv1beta3.NetworkPolicyTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-intra-namespace"},
+		Spec:       tenetv1beta3.NetworkPolicyTemplateSpec{PolicyTemplate: intraNSTemplate},
+	}
+	if !IsOptedIn(npt, ns) {
+		t.Error("namespace should opt into the template")
+	}
+
+	np, err := Render(npt, ns, newScheme(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if np.GetKind() != cilium.CiliumNetworkPolicy().GetKind() || np.GetNamespace() != "team-a" || np.GetName() != "allow-intra-namespace" {
+		t.Errorf("unexpected policy %s %s/%s", np.GetKind(), np.GetNamespace(), np.GetName())
+	}
+	if rev := np.GetAnnotations()[tenet.RevisionAnnotation]; rev != Revision(intraNSTemplate) || len(rev) != 10 {
+		t.Errorf("unexpected revision %q", rev)
+	}
+	if owners := np.GetOwnerReferences(); len(owners) != 1 || owners[0].Name != "allow-intra-namespace" {
+		t.Errorf("unexpected owner references %v", owners)
+	}
+
+	npt.Spec.ClusterWide = true
+	if _, err := Render(npt, ns, newScheme(t)); err == nil {
+		t.Error("rendering a CiliumNetworkPolicy from a cluster-wide template should fail")
+	}
+	if key := PolicyKey(npt, ns); key.Namespace != "" || key.Name != "team-a-allow-intra-namespace" {
+		t.Errorf("unexpected key %v", key)
+	}
+
+	npt.Spec.PolicyTemplate = "{{.Unknown}}"
+	if _, err := Render(npt, ns, newScheme(t)); err == nil {
+		t.Error("rendering an invalid template should fail")
+	}
+}
+
+func TestIsTemplateOwner(t *testing.T) {
+	tests := []struct {
+		apiVersion string
+		kind       string
+		want       bool
+	}{
+		{"tenet.cybozu.io/v1beta3", "NetworkPolicyTemplate", true},
+		{"tenet.cybozu.io/v1beta2", "NetworkPolicyTemplate", true},
+		{"tenet.cybozu.io/v1beta2", "NetworkPolicyAdmissionRule", false},
+		{"example.com/v1beta3", "NetworkPolicyTemplate", false},
+	}
+	for _, tt := range tests {
+		ref := metav1.OwnerReference{APIVersion: tt.apiVersion, Kind: tt.kind, Name: "allow-intra-namespace"}
+		if got := IsTemplateOwner(ref); got != tt.want {
+			t.Errorf("IsTemplateOwner(%s %s) = %t, want %t", tt.apiVersion, tt.kind, got, tt.want)
+		}
+	}
+}
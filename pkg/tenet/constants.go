@@ -3,4 +3,11 @@ package tenet
 const (
 	// PolicyAnnotation is the annotation used to opt-into a template.
 	PolicyAnnotation = "tenet.cybozu.io/network-policy-template"
+	// RevisionAnnotation is the annotation recording the template revision a generated policy was rendered from.
+	RevisionAnnotation = "tenet.cybozu.io/template-revision"
+	// RollbackAnnotation is the annotation requesting a template to be rolled back to its previous revision.
+	RollbackAnnotation = "tenet.cybozu.io/rollback"
+	// PausedAnnotation is the namespace annotation pausing the reconciliation of the policies generated for the namespace.
+	// Its value is the reason of the pause.
+	PausedAnnotation = "tenet.cybozu.io/paused"
 )